@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -60,10 +61,8 @@ func RenderDiff(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 
 	// Build render-diff args.
 	// We run render-diff with cmd.Dir=absChartPath and pass --path . so that
-	// render-diff treats values files as relative to the chart directory rather
-	// than joining them with an absolute --path (which doubles the path in Go's
-	// filepath.Join when values are already absolute).
-	args := []string{"--path", ".", "--no-color"}
+	// relative values files resolve against the chart directory.
+	args := []string{"--path", ".", "--no-color", "--exit-code"}
 
 	if gitRef := req.GetString("git_ref", ""); gitRef != "" {
 		args = append(args, "--ref", gitRef)
@@ -78,8 +77,9 @@ func RenderDiff(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 		args = append(args, "--update")
 	}
 	for _, f := range req.GetStringSlice("values_files", nil) {
-		// Convert absolute paths to relative (from absChartPath) so render-diff
-		// can resolve them correctly relative to --path .
+		// Current render-diff releases accept absolute values paths, but
+		// older ones join every values file onto --path, so pass paths
+		// relative to the chart directory where possible.
 		if filepath.IsAbs(f) {
 			if rel, relErr := filepath.Rel(absChartPath, f); relErr == nil {
 				f = rel
@@ -109,24 +109,26 @@ func RenderDiff(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 	hasDiff := false
 	summary := "No differences found"
 
-	if runErr != nil {
-		// render-diff exits non-zero when there are differences
-		if strings.Contains(output, "---") || strings.Contains(output, "+++") || strings.Contains(output, "Diff") {
-			hasDiff = true
-			summary = helmutil.SummarizeDiff(output)
-		} else {
-			// Actual error
-			return mcp.NewToolResultText(mcperr.New(
-				"render_diff_failed",
-				fmt.Sprintf("render-diff failed: %s %s", output, stderr.String()),
-				"Check that the chart_path is valid and git ref exists. Run `render-diff --path "+chartPath+"` manually",
-			).JSON()), nil
-		}
-	} else {
-		if strings.Contains(output, "---") || strings.Contains(output, "+++") {
-			hasDiff = true
-			summary = helmutil.SummarizeDiff(output)
+	// render-diff --exit-code returns 0 for no differences, 1 for
+	// differences and 2 or higher for render or git failures.
+	// Releases without --exit-code also exit 1 on their flag parsing error.
+	unsupported := strings.Contains(stderr.String(), "unknown flag: --exit-code")
+	var exitErr *exec.ExitError
+	switch {
+	case runErr == nil:
+	case errors.As(runErr, &exitErr) && exitErr.ExitCode() == 1 && !unsupported:
+		hasDiff = true
+		summary = helmutil.SummarizeDiff(output)
+	default:
+		hint := "Check that the chart_path is valid and git ref exists. Run `render-diff --path " + chartPath + "` manually"
+		if unsupported {
+			hint = "Upgrade render-diff: go install github.com/mozilla/mozcloud/tools/render-diff@latest"
 		}
+		return mcp.NewToolResultText(mcperr.New(
+			"render_diff_failed",
+			fmt.Sprintf("render-diff failed: %s %s", output, stderr.String()),
+			hint,
+		).JSON()), nil
 	}
 
 	res := renderDiffResult{
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/argocd"
+)
+
+// appEnvironments loads the Argo CD Applications passed with --app and
+// returns the path and render settings of each. Files passed with
+// --values are loaded before each Application's own value files.
+func appEnvironments() ([]string, []environment, error) {
+	apps, err := argocd.Load(appFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths := make([]string, len(apps))
+	environments := make([]environment, len(apps))
+	for i, app := range apps {
+		p := filepath.FromSlash(app.Path)
+		if strings.HasPrefix(p, "..") {
+			return nil, nil, fmt.Errorf("application '%s' source path '%s' is outside the git repository root", app.Name, app.Path)
+		}
+
+		paths[i] = p
+		environments[i] = environment{
+			name:        app.Name,
+			values:      append(append([]string{}, valuesFlag...), app.ValueFiles...),
+			releaseName: app.ReleaseName,
+			namespace:   app.Namespace,
+			inline:      app.Values,
+			set:         app.Set,
+			setString:   app.SetString,
+		}
+	}
+
+	log.Printf("Found %d Argo CD applications in '%s'", len(apps), appFlag)
+	return paths, environments, nil
+}
+
+// multiPath reports whether results can come from more than one path
+func multiPath() bool {
+	return changedFlag || appFlag != "" || isTargets()
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/discover"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/git"
+)
+
+// changedPaths returns the charts and kustomizations below scope that are
+// affected by the changes between the target ref and the local side.
+// Charts that only exist in the target ref were removed by the changes
+// and are always returned, so they are diffed as fully removed.
+func changedPaths(localRoot, targetRoot, scope string) ([]string, error) {
+	files, err := git.ChangedFiles(repoRoot, fullRef, toRef)
+	if err != nil {
+		return nil, err
+	}
+
+	roots, err := discover.Roots(localRoot, scope)
+	if err != nil {
+		return nil, err
+	}
+	for i := range roots {
+		if roots[i].Kind == discover.KindHelm {
+			roots[i].Values = rootValues(localRoot, targetRoot, roots[i].Path)
+		}
+	}
+
+	affected, err := discover.Affected(localRoot, roots, files)
+	if err != nil {
+		return nil, err
+	}
+
+	// The scope itself may have been added by the changes
+	if info, err := os.Stat(filepath.Join(targetRoot, scope)); err == nil && info.IsDir() {
+		targetRoots, err := discover.Roots(targetRoot, scope)
+		if err != nil {
+			return nil, err
+		}
+		affected = append(affected, discover.Removed(roots, targetRoots)...)
+	}
+
+	paths := make([]string, len(affected))
+	for i, root := range affected {
+		paths[i] = filepath.FromSlash(root.Path)
+	}
+	sort.Strings(paths)
+
+	if len(paths) == 0 {
+		log.Printf("No charts or kustomizations affected by %d changed files", len(files))
+	} else {
+		log.Printf("Found %d affected charts and kustomizations in %d changed files: %v", len(paths), len(files), paths)
+	}
+	return paths, nil
+}
+
+// rootValues returns the values files in the repository that the chart at
+// dir is rendered with, in any environment, relative to the repository root
+// with forward slashes. If the environments cannot be resolved, nil is
+// returned and the error is reported when the chart is rendered.
+func rootValues(localRoot, targetRoot, dir string) []string {
+	dir = filepath.FromSlash(dir)
+	environments, err := resolveEnvironments(chartDir(localRoot, targetRoot, dir))
+	if err != nil {
+		return nil
+	}
+
+	var values []string
+	for _, env := range environments {
+		for _, p := range locateValues(repoRoot, dir, env.values, localRoot, targetRoot) {
+			if !filepath.IsAbs(p) {
+				values = append(values, filepath.ToSlash(p))
+			}
+		}
+	}
+	return values
+}
+
+// chartDir returns the directory of the chart at path in the local tree,
+// or in the target work tree if the changes removed it
+func chartDir(localRoot, targetRoot, path string) string {
+	dir := filepath.Join(localRoot, path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return filepath.Join(targetRoot, path)
+	}
+	return dir
+}
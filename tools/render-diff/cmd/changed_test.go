@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRootValues(t *testing.T) {
+	t.Cleanup(resetFlags)
+	resetFlags()
+
+	repo := resolveSymlinks(t.TempDir())
+	worktree := t.TempDir()
+
+	for _, f := range []string{
+		filepath.Join(repo, "charts/web/Chart.yaml"),
+		filepath.Join(repo, "charts/web/values-dev.yaml"),
+		filepath.Join(repo, "common/values-prod.yaml"),
+		filepath.Join(worktree, "charts/old/values-prod.yaml"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte{}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repoRoot = repo
+	t.Chdir(repo)
+
+	testCases := []struct {
+		name   string
+		dir    string
+		values []string
+		matrix bool
+		want   []string
+	}{
+		{
+			name:   "Shared values file",
+			dir:    "charts/web",
+			values: []string{"../../common/values-prod.yaml"},
+			want:   []string{"common/values-prod.yaml"},
+		},
+		{
+			name:   "Matrix environments",
+			dir:    "charts/web",
+			values: []string{"common/values-prod.yaml"},
+			matrix: true,
+			want:   []string{"common/values-prod.yaml", "charts/web/values-dev.yaml"},
+		},
+		{
+			name:   "Chart removed locally",
+			dir:    "charts/old",
+			matrix: true,
+			want:   []string{"charts/old/values-prod.yaml"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			valuesFlag, matrixFlag = tc.values, tc.matrix
+			if got := rootValues(repo, worktree, tc.dir); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("rootValues(%q) = %v, want %v", tc.dir, got, tc.want)
+			}
+		})
+	}
+}
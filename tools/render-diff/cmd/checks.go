@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/destructive"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/policy"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/validate"
+)
+
+// defaultSchemaDir is the schema directory looked up in the repository
+// root when --validate is used without --schema-dir
+const defaultSchemaDir = "crdSchemas"
+
+// validatePolicyFlags checks that --fail-on-policy names a severity and
+// is only used together with --policy-dir
+func validatePolicyFlags() error {
+	if failPolicyFlag == "" {
+		return nil
+	}
+	if policyDirFlag == "" {
+		return fmt.Errorf("--fail-on-policy requires --policy-dir")
+	}
+	if _, err := policy.ParseSeverity(failPolicyFlag); err != nil {
+		return fmt.Errorf("invalid --fail-on-policy: %w", err)
+	}
+	return nil
+}
+
+// runChecks runs the checks on every render result. Destructive changes
+// are always detected, except between the two environments of a drift
+// comparison, and other checks are enabled by flags. Checks that fail
+// mark the run as failed through checksFailed. Every check runs on the
+// raw renders, so filters and ignore rules cannot hide or alter objects
+// they check.
+func runChecks(results []renderResult) error {
+	if !isDrift() {
+		for i := range results {
+			changes, err := destructive.Detect(results[i].rawTarget, results[i].rawLocal)
+			if err != nil {
+				return fmt.Errorf("failed to detect destructive changes%s: %w", results[i].label(), err)
+			}
+			results[i].destructive = changes
+			if failDestructiveFlag && len(changes) > 0 {
+				checksFailed = true
+			}
+		}
+	}
+
+	if validateFlag {
+		dir := schemaDirFlag
+		if dir == "" {
+			dir = filepath.Join(repoRoot, defaultSchemaDir)
+		}
+
+		validator, err := validate.New(dir)
+		if err != nil {
+			return fmt.Errorf("failed to load schemas, use --schema-dir to point to a schema directory: %w", err)
+		}
+
+		for i := range results {
+			targetFailures, err := validator.Validate(results[i].rawTarget)
+			if err != nil {
+				return fmt.Errorf("failed to validate target ref manifests%s: %w", results[i].label(), err)
+			}
+			localFailures, err := validator.Validate(results[i].rawLocal)
+			if err != nil {
+				return fmt.Errorf("failed to validate local manifests%s: %w", results[i].label(), err)
+			}
+
+			results[i].validation = validate.NewFailures(targetFailures, localFailures)
+			if len(results[i].validation) > 0 {
+				checksFailed = true
+			}
+		}
+	}
+
+	if policyDirFlag != "" {
+		rules, err := policy.LoadDir(policyDirFlag)
+		if err != nil {
+			return err
+		}
+
+		for i := range results {
+			violations, err := policy.Evaluate(rules, results[i].rawTarget, results[i].rawLocal)
+			if err != nil {
+				return fmt.Errorf("failed to check policies%s: %w", results[i].label(), err)
+			}
+			results[i].policy = violations
+			if failPolicyFlag != "" && policy.Failed(violations, policy.Severity(failPolicyFlag)) {
+				checksFailed = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// printDestructive prints the destructive changes of one result. It is
+// printed ahead of the diff, so the changes are not missed in long diffs.
+func printDestructive(res renderResult) error {
+	return destructive.Write(os.Stdout, res.destructive)
+}
+
+// printChecks prints the sections of the enabled checks for one result
+func printChecks(res renderResult) error {
+	if validateFlag {
+		if err := validate.WriteFailures(os.Stdout, res.validation); err != nil {
+			return err
+		}
+	}
+	if policyDirFlag != "" {
+		if err := policy.Write(os.Stdout, res.policy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
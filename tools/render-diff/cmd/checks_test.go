@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const checksDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      containers:
+        - name: app
+          image: app:1.0
+`
+
+const checksClaim = `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+`
+
+func TestRunChecksIgnoresFilters(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	// The filter and ignore rule hide both changes from the diff
+	includeKindFlag = []string{"ConfigMap"}
+	ignoreFlag = []string{"/spec/selector"}
+
+	results := []renderResult{{
+		target: checksDeployment + "---\n" + checksClaim,
+		local:  checksDeployment,
+	}}
+	if err := prepareResults(results); err != nil {
+		t.Fatalf("prepareResults() failed: %v", err)
+	}
+	if err := runChecks(results); err != nil {
+		t.Fatalf("runChecks() failed: %v", err)
+	}
+
+	if len(results[0].destructive) != 1 || results[0].destructive[0].ResourceID.Kind != "PersistentVolumeClaim" {
+		t.Errorf("Expected the removed claim to be destructive, got %v", results[0].destructive)
+	}
+}
+
+func TestRunChecksValidateIgnoresFilters(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	validateFlag = true
+	schemaDirFlag = "../internal/validate/testdata/schemas"
+
+	// The ignore rule drops a required field, and the filter excludes the kind
+	ignoreFlag = []string{"Widget:/spec/size"}
+	excludeKindFlag = []string{"Widget"}
+
+	widget := "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec:\n  size: 1\n"
+	results := []renderResult{{
+		target: widget,
+		local:  strings.Replace(widget, "size: 1", "size: 0", 1),
+	}}
+	if err := prepareResults(results); err != nil {
+		t.Fatalf("prepareResults() failed: %v", err)
+	}
+	if err := runChecks(results); err != nil {
+		t.Fatalf("runChecks() failed: %v", err)
+	}
+
+	if len(results[0].validation) != 1 {
+		t.Errorf("Expected the excluded widget to fail validation once, got %v", results[0].validation)
+	}
+}
+
+func TestRunChecksPolicyIgnoresFilters(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	policyDirFlag = t.TempDir()
+	rule := "rules:\n  - name: no-latest\n    match:\n      kind: Deployment\n    path: /spec/template/spec/containers/name=*/image\n    notMatches: ':latest$'\n"
+	if err := os.WriteFile(filepath.Join(policyDirFlag, "images.yaml"), []byte(rule), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ignore rule drops the field the policy checks from the diff
+	ignoreFlag = []string{"/spec/template/spec/containers/name=app/image"}
+
+	results := []renderResult{{
+		target: checksDeployment,
+		local:  strings.Replace(checksDeployment, "app:1.0", "app:latest", 1),
+	}}
+	if err := prepareResults(results); err != nil {
+		t.Fatalf("prepareResults() failed: %v", err)
+	}
+	if err := runChecks(results); err != nil {
+		t.Fatalf("runChecks() failed: %v", err)
+	}
+
+	if len(results[0].policy) != 1 || results[0].policy[0].Rule != "no-latest" {
+		t.Errorf("Expected the latest tag to violate the policy, got %v", results[0].policy)
+	}
+}
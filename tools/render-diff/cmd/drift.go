@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/git"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// isDrift reports whether two values sets are compared at the same ref
+func isDrift() bool {
+	return len(valuesAFlag) > 0 || len(valuesBFlag) > 0
+}
+
+// validateDriftFlags rejects flags that select git refs or environments,
+// since a drift comparison renders the working tree twice
+func validateDriftFlags(cmd *cobra.Command) error {
+	for _, name := range []string{"ref", "from", "to", "merge-base", "env", "matrix", "changed", "app", "target", "all", "fail-on-destructive"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s cannot be used with --values-a/--values-b", name)
+		}
+	}
+	if len(valuesAFlag) == 0 || len(valuesBFlag) == 0 {
+		return fmt.Errorf("--values-a and --values-b must be used together")
+	}
+
+	// The repository root is only used to find the default schema
+	// directory, so a drift comparison also works outside of git
+	if root, err := git.GetRepoRoot(); err == nil {
+		repoRoot = root
+	}
+
+	return nil
+}
+
+// driftLabel names one side of a drift comparison by its values files
+func driftLabel(values []string) string {
+	return strings.Join(values, ",")
+}
+
+// runDrift renders the chart at renderPathFlag with each values set and
+// diffs the renders, without setting up any work trees
+func runDrift(ctx context.Context) error {
+	chartPath, err := filepath.Abs(renderPathFlag)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+	}
+
+	fromLabel, toLabel := driftLabel(valuesAFlag), driftLabel(valuesBFlag)
+	log.Printf("Starting diff of values '%s' against values '%s':", toLabel, fromLabel)
+
+	// --values files are loaded first on both sides
+	valuesA := driftValues(chartPath, append(append([]string{}, valuesFlag...), valuesAFlag...))
+	valuesB := driftValues(chartPath, append(append([]string{}, valuesFlag...), valuesBFlag...))
+
+	res := renderResult{
+		from: fmt.Sprintf("%s (%s)", renderPathFlag, fromLabel),
+		to:   fmt.Sprintf("%s (%s)", renderPathFlag, toLabel),
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		render, err := diff.RenderManifests(chartPath, environment{}.renderOptions(valuesA))
+		if err != nil {
+			return fmt.Errorf("failed to render with --values-a: %w", err)
+		}
+		res.target = render
+		return nil
+	})
+	g.Go(func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		render, err := diff.RenderManifests(chartPath, environment{}.renderOptions(valuesB))
+		if err != nil {
+			return fmt.Errorf("failed to render with --values-b: %w", err)
+		}
+		res.local = render
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	results := []renderResult{res}
+	if err := prepareResults(results); err != nil {
+		return err
+	}
+	if err := runChecks(results); err != nil {
+		return err
+	}
+	if err := runReports(results); err != nil {
+		return err
+	}
+
+	diffFound, err = writeOutput(results)
+	return err
+}
+
+// driftValues locates the values files of one side. Both sides render the
+// working tree, so files are found relative to the chart or the current
+// directory rather than a repository root.
+func driftValues(chartPath string, values []string) []string {
+	return valuesPaths(chartPath, locateValues(chartPath, ".", values, chartPath))
+}
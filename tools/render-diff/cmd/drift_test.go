@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	path := "../examples/helm/helloWorld"
+
+	t.Run("Diffs two values sets", func(t *testing.T) {
+		stdout, stderr, err := executeCommand(context.Background(), "--path", path, "--values-a", "values.yaml", "--values-b", "values-dev.yaml", "--no-color")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		if !diffFound {
+			t.Error("Expected differences between values.yaml and values-dev.yaml")
+		}
+		if !strings.Contains(stdout, "--- Diff (values.yaml vs. values-dev.yaml) ---") {
+			t.Errorf("Expected diff header in stdout, got:\n%s", stdout)
+		}
+		if strings.Contains(stderr, "Starting diff against git ref") {
+			t.Error("Drift comparison should not use git refs")
+		}
+	})
+
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "Requires both sides", args: []string{"--values-a", "values.yaml"}, wantErr: "must be used together"},
+		{name: "Rejects --ref", args: []string{"--values-a", "values.yaml", "--values-b", "values-dev.yaml", "--ref", "HEAD"}, wantErr: "--ref cannot be used"},
+		{name: "Rejects --matrix", args: []string{"--values-a", "values.yaml", "--values-b", "values-dev.yaml", "--matrix"}, wantErr: "--matrix cannot be used"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := executeCommand(context.Background(), append([]string{"--path", path}, tc.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/helm"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/ignore"
+)
+
+// environment is a named set of values files rendered on both sides of the
+// diff. Environments derived from Argo CD Applications also carry the
+// Application's release name, namespace and inline values, and project
+// targets their own ignore rules.
+type environment struct {
+	name   string
+	values []string
+
+	releaseName string
+	namespace   string
+	inline      []map[string]any
+	set         []string
+	setString   []string
+	ignore      []ignore.Rule
+}
+
+// renderOptions returns the helm render options for the environment,
+// using the given values file paths
+func (e environment) renderOptions(valuesPaths []string) helm.RenderOptions {
+	releaseName := e.releaseName
+	if releaseName == "" {
+		releaseName = releaseNameFlag
+	}
+	return helm.RenderOptions{
+		ReleaseName:  releaseName,
+		Namespace:    e.releaseNamespace(),
+		ValuesFiles:  valuesPaths,
+		Values:       e.inline,
+		SetJSON:      setJSONFlag,
+		Set:          slices.Concat(e.set, setFlag),
+		SetString:    slices.Concat(e.setString, setStringFlag),
+		SetFile:      setFileFlag,
+		Capabilities: renderCapabilities,
+		Debug:        debugFlag,
+		Update:       updateFlag,
+	}
+}
+
+// releaseNamespace returns the namespace the environment is rendered
+// into, or an empty string for Helm's default
+func (e environment) releaseNamespace() string {
+	if e.namespace != "" {
+		return e.namespace
+	}
+	return releaseNamespaceFlag
+}
+
+// label returns a suffix for error messages identifying the environment
+func (e environment) label() string {
+	if e.name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" for environment '%s'", e.name)
+}
+
+// isMatrix reports whether more than the default environment was requested
+func isMatrix() bool {
+	return matrixFlag || len(envFlag) > 0
+}
+
+// resolveEnvironments builds the list of environments to render.
+// Values passed with --values are loaded first for every environment,
+// followed by the environment specific files.
+func resolveEnvironments(chartPath string) ([]environment, error) {
+	if !isMatrix() {
+		return []environment{{values: valuesFlag}}, nil
+	}
+
+	var environments []environment
+	seen := make(map[string]bool)
+
+	add := func(env environment) error {
+		if seen[env.name] {
+			return fmt.Errorf("environment '%s' is defined more than once", env.name)
+		}
+		seen[env.name] = true
+		env.values = append(append([]string{}, valuesFlag...), env.values...)
+		environments = append(environments, env)
+		return nil
+	}
+
+	for _, spec := range envFlag {
+		env, err := parseEnvironment(spec)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(env); err != nil {
+			return nil, err
+		}
+	}
+
+	if matrixFlag {
+		discovered, err := discoverEnvironments(chartPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, env := range discovered {
+			// Explicit --env definitions take precedence over discovered files
+			if seen[env.name] {
+				continue
+			}
+			if err := add(env); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(environments) == 0 {
+		return nil, fmt.Errorf("no environments found in '%s': expected values-<env>.yaml files or --env flags", chartPath)
+	}
+
+	return environments, nil
+}
+
+// parseEnvironment parses an --env value. It accepts either
+// "name=values-a.yaml,values-b.yaml" or a single values file,
+// in which case the name is derived from the file name.
+func parseEnvironment(spec string) (environment, error) {
+	name, files, found := strings.Cut(spec, "=")
+	if !found {
+		files = spec
+		name = environmentName(spec)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return environment{}, fmt.Errorf("invalid --env %q: environment name is empty", spec)
+	}
+
+	var values []string
+	for _, f := range strings.Split(files, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			values = append(values, f)
+		}
+	}
+	if len(values) == 0 {
+		return environment{}, fmt.Errorf("invalid --env %q: no values files provided", spec)
+	}
+
+	return environment{name: name, values: values}, nil
+}
+
+// discoverEnvironments finds values-<env>.yaml files in the chart directory.
+// The chart's own values.yaml is always loaded by Helm and is not an environment.
+func discoverEnvironments(chartPath string) ([]environment, error) {
+	entries, err := os.ReadDir(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart directory for environments: %w", err)
+	}
+
+	var environments []environment
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if !strings.HasPrefix(name, "values-") || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		environments = append(environments, environment{
+			name:   environmentName(name),
+			values: []string{name},
+		})
+	}
+
+	sort.Slice(environments, func(i, j int) bool {
+		return environments[i].name < environments[j].name
+	})
+
+	return environments, nil
+}
+
+// environmentName derives an environment name from a values file,
+// e.g. "values-prod.yaml" becomes "prod"
+func environmentName(valuesFile string) string {
+	base := filepath.Base(valuesFile)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	if trimmed := strings.TrimPrefix(base, "values-"); trimmed != "" {
+		return trimmed
+	}
+	return base
+}
+
+// printMatrix prints a grouped report with one section per environment
+// or changed chart, followed by a summary. It reports whether any
+// section had differences.
+func printMatrix(results []renderResult) (bool, error) {
+	anyDiff := false
+	summaries := make([]string, len(results))
+
+	for i, res := range results {
+		fmt.Printf("\n=== %s ===\n", res.title())
+
+		hasDiff, err := printResult(res)
+		if err != nil {
+			return anyDiff, fmt.Errorf("failed to diff%s: %w", res.label(), err)
+		}
+		anyDiff = anyDiff || hasDiff
+
+		summaries[i] = summarizeResult(res, hasDiff)
+		if note := resultNote(res); note != "" {
+			summaries[i] += fmt.Sprintf(" (%s)", note)
+		}
+	}
+
+	if multiPath() {
+		fmt.Println("\nSummary:")
+	} else {
+		fmt.Println("\nEnvironment summary:")
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, res := range results {
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\n", res.name(), summaries[i])
+	}
+	return anyDiff, tw.Flush()
+}
+
+// title returns the section heading of a result in grouped output
+func (r renderResult) title() string {
+	env := fmt.Sprintf("Environment: %s (%s)", r.env.name, strings.Join(r.env.values, ", "))
+	switch {
+	case !multiPath():
+		return env
+	case r.env.name == "":
+		return fmt.Sprintf("Path: %s", r.path)
+	default:
+		return fmt.Sprintf("Path: %s, %s", r.path, env)
+	}
+}
+
+// name identifies a result in summaries
+func (r renderResult) name() string {
+	switch {
+	case !multiPath():
+		return r.env.name
+	case r.env.name == "":
+		return r.path
+	default:
+		return fmt.Sprintf("%s (%s)", r.path, r.env.name)
+	}
+}
+
+// summarizeResult returns a one line description of the changes in one
+// environment. Object counts come from the semantic comparison, which is
+// available in both diff modes for Kubernetes manifests.
+func summarizeResult(res renderResult, hasDiff bool) string {
+	if !hasDiff {
+		return "no differences"
+	}
+
+	report, err := diff.CompareRenders(res.target, res.local, res.from, res.to)
+	if err != nil {
+		return "differences found"
+	}
+
+	summary := diff.SummarizeChanges(report)
+	if summary.Total() == 0 {
+		return "differences found (formatting only)"
+	}
+	return summary.String()
+}
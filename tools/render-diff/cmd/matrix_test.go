@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvironment(t *testing.T) {
+	testCases := []struct {
+		name    string
+		spec    string
+		want    environment
+		wantErr bool
+	}{
+		{
+			name: "Named environment with multiple files",
+			spec: "prod-eu=values-prod.yaml,values-prod-eu.yaml",
+			want: environment{name: "prod-eu", values: []string{"values-prod.yaml", "values-prod-eu.yaml"}},
+		},
+		{
+			name: "Name derived from values file",
+			spec: "values-stage.yaml",
+			want: environment{name: "stage", values: []string{"values-stage.yaml"}},
+		},
+		{
+			name:    "Empty name",
+			spec:    "=values-prod.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "No values files",
+			spec:    "prod=",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseEnvironment(tc.spec)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseEnvironment(%q) error = %v, wantErr %v", tc.spec, err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseEnvironment(%q) = %+v, want %+v", tc.spec, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"values.yaml", "values-prod.yaml", "values-dev.yml", "Chart.yaml", "values-notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte{}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := discoverEnvironments(dir)
+	if err != nil {
+		t.Fatalf("discoverEnvironments() failed: %v", err)
+	}
+
+	want := []environment{
+		{name: "dev", values: []string{"values-dev.yml"}},
+		{name: "prod", values: []string{"values-prod.yaml"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("discoverEnvironments() = %+v, want %+v", got, want)
+	}
+}
+
+func TestResolveEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"values-dev.yaml", "values-prod.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte{}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Cleanup(func() {
+		valuesFlag, envFlag, matrixFlag = []string{}, []string{}, false
+	})
+
+	t.Run("Default single environment", func(t *testing.T) {
+		valuesFlag, envFlag, matrixFlag = []string{"values-dev.yaml"}, []string{}, false
+
+		got, err := resolveEnvironments(dir)
+		if err != nil {
+			t.Fatalf("resolveEnvironments() failed: %v", err)
+		}
+		want := []environment{{values: []string{"values-dev.yaml"}}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("resolveEnvironments() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Explicit environments override discovered ones", func(t *testing.T) {
+		valuesFlag = []string{"common.yaml"}
+		envFlag = []string{"prod=values-prod.yaml,values-prod-eu.yaml"}
+		matrixFlag = true
+
+		got, err := resolveEnvironments(dir)
+		if err != nil {
+			t.Fatalf("resolveEnvironments() failed: %v", err)
+		}
+		want := []environment{
+			{name: "prod", values: []string{"common.yaml", "values-prod.yaml", "values-prod-eu.yaml"}},
+			{name: "dev", values: []string{"common.yaml", "values-dev.yaml"}},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("resolveEnvironments() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Duplicate environment names", func(t *testing.T) {
+		valuesFlag = []string{}
+		envFlag = []string{"prod=a.yaml", "prod=b.yaml"}
+		matrixFlag = false
+
+		if _, err := resolveEnvironments(dir); err == nil {
+			t.Error("resolveEnvironments() succeeded, expected an error for duplicate names")
+		}
+	})
+}
+
+func TestRenderOptions(t *testing.T) {
+	t.Cleanup(func() {
+		releaseNameFlag, releaseNamespaceFlag, setFlag = "", "", []string{}
+	})
+	releaseNameFlag, releaseNamespaceFlag, setFlag = "web", "web-dev", []string{"image.tag=v2"}
+
+	testCases := []struct {
+		name          string
+		env           environment
+		wantRelease   string
+		wantNamespace string
+		wantSet       []string
+	}{
+		{
+			name:          "Flags are used by default",
+			env:           environment{},
+			wantRelease:   "web",
+			wantNamespace: "web-dev",
+			wantSet:       []string{"image.tag=v2"},
+		},
+		{
+			name:          "Environment settings take precedence",
+			env:           environment{releaseName: "api", namespace: "api-prod", set: []string{"image.tag=v1"}},
+			wantRelease:   "api",
+			wantNamespace: "api-prod",
+			// --set is applied after the environment's parameters
+			wantSet: []string{"image.tag=v1", "image.tag=v2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.env.renderOptions(nil)
+			if opts.ReleaseName != tc.wantRelease || opts.Namespace != tc.wantNamespace {
+				t.Errorf("renderOptions() = %q/%q, want %q/%q", opts.ReleaseName, opts.Namespace, tc.wantRelease, tc.wantNamespace)
+			}
+			if !reflect.DeepEqual(opts.Set, tc.wantSet) {
+				t.Errorf("renderOptions() set = %v, want %v", opts.Set, tc.wantSet)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/report"
+)
+
+// Supported values for the --output flag
+const (
+	outputText     = "text"
+	outputJSON     = "json"
+	outputMarkdown = "markdown"
+)
+
+var outputFormats = []string{outputText, outputJSON, outputMarkdown}
+
+// validateOutputFlag checks that --output is a supported format
+func validateOutputFlag() error {
+	if !slices.Contains(outputFormats, outputFlag) {
+		return fmt.Errorf("invalid --output %q: must be one of %v", outputFlag, outputFormats)
+	}
+	return nil
+}
+
+// buildReport compares every render result and collects the changes
+// into a machine-readable report
+func buildReport(results []renderResult) (*report.Report, error) {
+	rep := report.New(targetLabel())
+	if localLabel() != "local" {
+		rep.ToRef = localLabel()
+	}
+	rep.Filter = selector().String()
+	rep.KubeVersion = checkKubeVersion
+
+	for _, res := range results {
+		compared, err := diff.CompareRenders(res.target, res.local, res.from, res.to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare manifests%s: %w", res.label(), err)
+		}
+		result := report.NewResult(res.env.name, res.from, res.to, diff.Changes(compared))
+		if multiPath() {
+			result.Path = filepath.ToSlash(res.path)
+		}
+		result.Filtered = res.filtered
+		result.Suppressed = res.suppressed
+		result.Validation = res.validation
+		result.Destructive = res.destructive
+		result.Policy = res.policy
+		result.Images = res.images
+		result.Capacity = res.capacity
+		result.Security = res.security
+		result.Deprecations = res.deprecations
+
+		if outputFlag == outputMarkdown {
+			result.Diffs, err = diff.CreateResourceDiffs(res.target, res.local, result.Resources, res.from, res.to)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create resource diffs%s: %w", res.label(), err)
+			}
+			result.FullDiff = diff.CreateDiff(res.target, res.local, res.from, res.to)
+		}
+
+		rep.Results = append(rep.Results, result)
+	}
+
+	return rep, nil
+}
+
+// writeOutput writes the results in the format selected with --output
+// and reports whether any differences were found
+func writeOutput(results []renderResult) (bool, error) {
+	switch outputFlag {
+	case outputJSON:
+		return writeJSONReport(results)
+	case outputMarkdown:
+		return writeMarkdownReport(results)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("\nNo charts or kustomizations were rendered.")
+		return false, nil
+	}
+	if isMatrix() || multiPath() {
+		return printMatrix(results)
+	}
+	return printResult(results[0])
+}
+
+// printResult prints the diff for one render result followed by
+// the reports and the sections of the enabled checks
+func printResult(res renderResult) (bool, error) {
+	if err := printDestructive(res); err != nil {
+		return false, err
+	}
+	hasDiff, err := printDiff(res)
+	if err != nil {
+		return hasDiff, err
+	}
+	if err := printReports(res); err != nil {
+		return hasDiff, err
+	}
+	if note := resultNote(res); note != "" {
+		fmt.Printf("\n%s.\n", note)
+	}
+	return hasDiff, printChecks(res)
+}
+
+// resultNote describes the objects and changes hidden from a result
+// by filters and ignore rules, or returns an empty string
+func resultNote(res renderResult) string {
+	var notes []string
+	if res.filtered > 0 {
+		objectStr := "object"
+		if res.filtered != 1 {
+			objectStr = "objects"
+		}
+		notes = append(notes, fmt.Sprintf("%d %s excluded by filters (%s)", res.filtered, objectStr, selector()))
+	}
+	if res.suppressed > 0 {
+		changeStr := "change"
+		if res.suppressed != 1 {
+			changeStr = "changes"
+		}
+		notes = append(notes, fmt.Sprintf("%d %s suppressed by ignore rules", res.suppressed, changeStr))
+	}
+	return strings.Join(notes, ", ")
+}
+
+// writeJSONReport writes the report for all results to stdout as JSON
+func writeJSONReport(results []renderResult) (bool, error) {
+	rep, err := buildReport(results)
+	if err != nil {
+		return false, err
+	}
+	return rep.HasChanges(), rep.WriteJSON(os.Stdout)
+}
+
+// writeMarkdownReport writes the report for all results to stdout as GitHub
+// flavored markdown. If the report had to be truncated to fit in a PR comment
+// the full unified diff is written to --full-diff-file.
+func writeMarkdownReport(results []renderResult) (bool, error) {
+	rep, err := buildReport(results)
+	if err != nil {
+		return false, err
+	}
+
+	truncated, err := rep.WriteMarkdown(os.Stdout, report.MarkdownOptions{FullDiffPath: fullDiffFileFlag})
+	if err != nil {
+		return true, err
+	}
+	if !truncated {
+		return rep.HasChanges(), nil
+	}
+
+	var full strings.Builder
+	for _, result := range rep.Results {
+		if result.Path != "" {
+			fmt.Fprintf(&full, "# Path: %s\n", result.Path)
+		}
+		if result.Environment != "" {
+			fmt.Fprintf(&full, "# Environment: %s\n", result.Environment)
+		}
+		full.WriteString(result.FullDiff)
+	}
+
+	if err := os.WriteFile(fullDiffFileFlag, []byte(full.String()), 0o644); err != nil {
+		return true, fmt.Errorf("failed to write full diff to %s: %w", fullDiffFileFlag, err)
+	}
+	log.Printf("Markdown output truncated, full diff written to %s", fullDiffFileFlag)
+
+	return true, nil
+}
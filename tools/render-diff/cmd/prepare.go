@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/filter"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/ignore"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/redact"
+)
+
+// selector builds the object selector from the filter flags
+func selector() filter.Selector {
+	return filter.Selector{
+		IncludeKinds: includeKindFlag,
+		ExcludeKinds: excludeKindFlag,
+		Names:        nameFlag,
+		Namespaces:   namespaceFlag,
+	}
+}
+
+// loadIgnoreRules collects the rules from --ignore-file and --ignore
+func loadIgnoreRules() ([]ignore.Rule, error) {
+	var rules []ignore.Rule
+
+	if ignoreFileFlag != "" {
+		fileRules, err := ignore.LoadFile(ignoreFileFlag)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	for _, spec := range ignoreFlag {
+		rule, err := ignore.ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// prepareResults rewrites the renders of every result before they are
+// diffed, so every output format sees the same manifests
+func prepareResults(results []renderResult) error {
+	rules, err := loadIgnoreRules()
+	if err != nil {
+		return err
+	}
+
+	for i := range results {
+		res := &results[i]
+
+		// Redact before anything else, so Secret values never reach
+		// any output unless --show-secrets is set
+		if !showSecretsFlag {
+			if res.target, err = redact.Secrets(res.target); err != nil {
+				return fmt.Errorf("failed to redact target ref secrets%s: %w", res.label(), err)
+			}
+			if res.local, err = redact.Secrets(res.local); err != nil {
+				return fmt.Errorf("failed to redact local secrets%s: %w", res.label(), err)
+			}
+		}
+
+		res.rawTarget, res.rawLocal = res.target, res.local
+
+		// Objects without a namespace are matched in the namespace
+		// they were rendered for
+		sel := selector()
+		sel.ReleaseNamespace = res.env.releaseNamespace()
+		res.target, res.local, res.filtered, err = filter.Apply(res.target, res.local, sel)
+		if err != nil {
+			return fmt.Errorf("failed to filter manifests%s: %w", res.label(), err)
+		}
+
+		res.target, res.local, res.suppressed, err = ignore.Apply(res.target, res.local, slices.Concat(rules, res.env.ignore))
+		if err != nil {
+			return fmt.Errorf("failed to apply ignore rules%s: %w", res.label(), err)
+		}
+
+		// Normalize last, so the canonical form is what gets diffed
+		if normalizeFlag {
+			if res.target, err = manifest.Normalize(res.target); err != nil {
+				return fmt.Errorf("failed to normalize target render%s: %w", res.label(), err)
+			}
+			if res.local, err = manifest.Normalize(res.local); err != nil {
+				return fmt.Errorf("failed to normalize local render%s: %w", res.label(), err)
+			}
+		}
+	}
+
+	return nil
+}
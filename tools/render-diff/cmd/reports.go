@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/capacity"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/deprecation"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/images"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/security"
+)
+
+// runReports collects the reports shown next to the diff of every render
+// result. Unlike checks, reports never change the exit code.
+func runReports(results []renderResult) error {
+	for i := range results {
+		changes, err := images.Compare(results[i].target, results[i].local)
+		if err != nil {
+			return fmt.Errorf("failed to compare images%s: %w", results[i].label(), err)
+		}
+		results[i].images = changes
+
+		results[i].capacity, err = capacity.Compare(results[i].target, results[i].local)
+		if err != nil {
+			return fmt.Errorf("failed to compare capacity%s: %w", results[i].label(), err)
+		}
+
+		results[i].security, err = security.Compare(results[i].target, results[i].local)
+		if err != nil {
+			return fmt.Errorf("failed to compare RBAC and network exposure%s: %w", results[i].label(), err)
+		}
+
+		if checkKubeVersion != "" {
+			results[i].deprecations, err = deprecation.Check(results[i].target, results[i].local, checkKubeVersion)
+			if err != nil {
+				return fmt.Errorf("failed to check API versions%s: %w", results[i].label(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// printReports prints the report sections of one result after its diff
+func printReports(res renderResult) error {
+	if err := images.Write(os.Stdout, res.images); err != nil {
+		return err
+	}
+	if err := capacity.Write(os.Stdout, res.capacity); err != nil {
+		return err
+	}
+	if err := security.Write(os.Stdout, res.security); err != nil {
+		return err
+	}
+	return deprecation.Write(os.Stdout, checkKubeVersion, res.deprecations)
+}
@@ -13,26 +13,83 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/capacity"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/config"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/deprecation"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/destructive"
 	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
 	"github.com/mozilla/mozcloud/tools/render-diff/internal/git"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/helm"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/images"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/policy"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/redact"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/security"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/validate"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
+	"helm.sh/helm/v3/pkg/chartutil"
 )
 
 // Package vars
 // Includes flag vars and some set during PreRun
 var (
-	valuesFlag       []string
-	releaseNameFlag  string
-	renderPathFlag   string
-	gitRefFlag       string
-	updateFlag       bool
-	debugFlag        bool
-	semanticDiffFlag bool
-	noColorFlag      bool
+	valuesFlag           []string
+	releaseNameFlag      string
+	releaseNamespaceFlag string
+	setFlag              []string
+	setStringFlag        []string
+	setFileFlag          []string
+	setJSONFlag          []string
+	kubeVersionFlag      string
+	apiVersionsFlag      []string
+	capabilitiesFlag     string
+	renderPathFlag       string
+	gitRefFlag           string
+	fromFlag             string
+	toFlag               string
+	mergeBaseFlag        bool
+	changedFlag          bool
+	appFlag              string
+	targetFlag           []string
+	allFlag              bool
+	valuesAFlag          []string
+	valuesBFlag          []string
+	updateFlag           bool
+	debugFlag            bool
+	semanticDiffFlag     bool
+	normalizeFlag        bool
+	noColorFlag          bool
+	envFlag              []string
+	matrixFlag           bool
+	jobsFlag             int
+	outputFlag           string
+	fullDiffFileFlag     string
+	exitCodeFlag         bool
+	validateFlag         bool
+	failDestructiveFlag  bool
+	policyDirFlag        string
+	failPolicyFlag       string
+	schemaDirFlag        string
+	ignoreFlag           []string
+	ignoreFileFlag       string
+	showSecretsFlag      bool
+	includeKindFlag      []string
+	excludeKindFlag      []string
+	nameFlag             []string
+	namespaceFlag        []string
 
 	repoRoot string
 	fullRef  string
+	toRef    string
+	// projectTargets are the targets selected with --target or --all
+	projectTargets []config.Target
+	// renderCapabilities are the cluster capabilities used by every helm render
+	renderCapabilities *chartutil.Capabilities
+	// checkKubeVersion is the Kubernetes version API versions are checked
+	// against, e.g. "1.30". It is empty unless a version was set.
+	checkKubeVersion string
+	diffFound        bool
+	checksFailed     bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -41,11 +98,49 @@ var rootCmd = &cobra.Command{
 	Short: "A CLI tool to render Helm/Kustomize and diff manifests between a local revision and target ref.",
 	Long: `render-diff provides a fast and local preview of your Kubernetes manifest changes.
 
-It renders your local Helm chart or Kustomize overlay to compare the resulting manifests against the version in a target git ref (like 'main' or 'develop'). It prints a colored diff of the final rendered YAML.`,
+It renders your local Helm chart or Kustomize overlay to compare the resulting manifests against the version in a target git ref (like 'main' or 'develop'). It prints a colored diff of the final rendered YAML.
+
+Exit codes:
+  0  success. With --exit-code, no differences were found
+  1  differences were found (only with --exit-code)
+  2  an error occurred while rendering, running git or parsing flags
+  3  a check failed, e.g. --validate found objects that newly fail validation
+     or --fail-on-destructive found destructive changes, or --fail-on-policy
+     found policy violations, or 'snapshot --check' found outdated snapshots`,
 	Version: getVersion(),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		log.SetFlags(0) // Disabling timestamps for log output
 
+		if err := validateOutputFlag(); err != nil {
+			return err
+		}
+		if err := selector().Validate(); err != nil {
+			return err
+		}
+		if err := loadCapabilities(); err != nil {
+			return err
+		}
+		if err := validatePolicyFlags(); err != nil {
+			return err
+		}
+		if isDrift() {
+			return validateDriftFlags(cmd)
+		}
+		if appFlag != "" {
+			for _, name := range []string{"changed", "env", "matrix", "release-name", "release-namespace"} {
+				if cmd.Flags().Changed(name) {
+					return fmt.Errorf("--%s cannot be used with --app", name)
+				}
+			}
+		}
+		if isTargets() {
+			for _, name := range []string{"path", "changed", "app", "env", "matrix", "release-name", "release-namespace"} {
+				if cmd.Flags().Changed(name) {
+					return fmt.Errorf("--%s cannot be used with --target/--all", name)
+				}
+			}
+		}
+
 		// A local git installation is required
 		_, err := exec.LookPath("git")
 		if err != nil {
@@ -58,36 +153,57 @@ It renders your local Helm chart or Kustomize overlay to compare the resulting m
 			return err
 		}
 
-		// Try to find the upstream for our target ref
-		upstreamRef := exec.Command("git", "rev-parse", "--abbrev-ref", gitRefFlag+"@{u}")
-		upstreamRef.Dir = repoRoot
-
-		output, err := upstreamRef.CombinedOutput()
-		if err == nil {
-			fullRef = strings.TrimSpace(string(output))
-			if debugFlag {
-				log.Printf("Found upstream for '%s', using '%s'", gitRefFlag, fullRef)
-			}
-		} else {
-			fullRef = gitRefFlag
-			if debugFlag {
-				log.Printf("No upstream found for '%s', using local ref", fullRef)
+		if isTargets() {
+			if err := loadTargets(cmd); err != nil {
+				return err
 			}
 		}
 
-		// Validate
-		validateRef := exec.Command("git", "rev-parse", "--verify", "--quiet", fullRef)
-		validateRef.Dir = repoRoot
+		// --from is an alias of --ref that reads better next to --to
+		if fromFlag != "" {
+			gitRefFlag = fromFlag
+		}
 
-		if out, err := validateRef.CombinedOutput(); err != nil {
-			return fmt.Errorf("invalid or non-existent ref %q: %s", fullRef, strings.TrimSpace(string(out)))
+		fullRef, err = resolveRef(gitRefFlag)
+		if err != nil {
+			return err
+		}
+
+		if toFlag != "" {
+			toRef, err = resolveRef(toFlag)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Compare against the point where the branch forked from the
+		// target ref, so changes made on the target since are not shown
+		if mergeBaseFlag {
+			head := toRef
+			if head == "" {
+				head = "HEAD"
+			}
+			base, err := git.MergeBase(repoRoot, head, fullRef)
+			if err != nil {
+				return err
+			}
+			log.Printf("Using merge base %s of '%s' and '%s'", base, head, fullRef)
+			fullRef = base
 		}
 
 		return nil
 	},
 
 	RunE: func(cmd *cobra.Command, args []string) error {
-		log.Printf("Starting diff against git ref '%s':", fullRef)
+		if isDrift() {
+			return runDrift(cmd.Context())
+		}
+
+		if toRef != "" {
+			log.Printf("Starting diff of git ref '%s' against git ref '%s':", toRef, fullRef)
+		} else {
+			log.Printf("Starting diff against git ref '%s':", fullRef)
+		}
 
 		// Get the absolute path from the path flag
 		absPath, err := filepath.Abs(renderPathFlag)
@@ -105,104 +221,320 @@ It renders your local Helm chart or Kustomize overlay to compare the resulting m
 			return fmt.Errorf("the provided path '%s' (resolves to '%s') is outside the git repository root '%s'", renderPathFlag, absPath, repoRoot)
 		}
 
-		localPath := filepath.Join(repoRoot, relativePath)
-
-		// Resolve relative values file paths to absolute paths for the local render
-		// This means we only support values files located in the path provided
-		localValuesPaths := make([]string, len(valuesFlag))
-		for i, v := range valuesFlag {
-			localValuesPaths[i] = filepath.Join(localPath, v)
+		// Setup temporary work trees for diffs
+		// A single work tree per ref is shared by every environment
+		refs := []string{fullRef}
+		if toRef != "" {
+			refs = append(refs, toRef)
 		}
-
-		// Setup temporary work tree for diffs
-		tempDir, cleanup, err := git.SetupWorkTree(repoRoot, fullRef)
+		tempDirs, cleanup, err := git.SetupWorkTrees(cmd.Context(), repoRoot, refs...)
 		if err != nil {
 			return err
 		}
 		// We want this to run after we have generated our diffs
 		defer cleanup()
 
-		targetPath := filepath.Join(tempDir, relativePath)
+		targetRoot := tempDirs[0]
+
+		// With --to the local side is rendered from a work tree as well
+		localRoot := repoRoot
+		if toRef != "" {
+			localRoot = tempDirs[1]
+		}
+
+		// With --changed, every chart and kustomization below the path
+		// that is affected by the changes is rendered
+		paths := []string{relativePath}
+		if changedFlag {
+			paths, err = changedPaths(localRoot, targetRoot, relativePath)
+			if err != nil {
+				return err
+			}
+		}
+
+		var results []renderResult
+
+		// With --app or --target, each Argo CD Application or project
+		// target is rendered with its own path and settings
+		if appFlag != "" || isTargets() {
+			load := appEnvironments
+			if isTargets() {
+				load = targetEnvironments
+			}
+			namedPaths, environments, err := load()
+			if err != nil {
+				return err
+			}
+			paths = nil
+			for i, p := range namedPaths {
+				results = append(results, renderResult{
+					env:  environments[i],
+					path: p,
+					from: fmt.Sprintf("%s/%s", targetLabel(), p),
+					to:   fmt.Sprintf("%s/%s", localLabel(), p),
+				})
+			}
+		}
+
+		// Resolve the environments to render for each path. Without --env
+		// or --matrix this is a single unnamed environment built from --values
+		for _, p := range paths {
+			environments, err := resolveEnvironments(chartDir(localRoot, targetRoot, p))
+			if err != nil {
+				return err
+			}
+			for _, env := range environments {
+				results = append(results, renderResult{
+					env:  env,
+					path: p,
+					from: fmt.Sprintf("%s/%s", targetLabel(), p),
+					to:   fmt.Sprintf("%s/%s", localLabel(), p),
+				})
+			}
+		}
+
+		if err := renderEnvironments(cmd.Context(), localRoot, targetRoot, results); err != nil {
+			return err
+		}
+
+		if err := prepareResults(results); err != nil {
+			return err
+		}
+
+		if err := runChecks(results); err != nil {
+			return err
+		}
+		if err := runReports(results); err != nil {
+			return err
+		}
+
+		diffFound, err = writeOutput(results)
+		return err
+	},
+}
 
-		// Resolve values file paths for the worktree
-		targetValuesPaths := make([]string, len(valuesFlag))
-		for i, v := range valuesFlag {
-			targetValuesPaths[i] = filepath.Join(targetPath, v)
+// resolveRef returns the remote-tracking branch of ref if it has one,
+// or ref itself, and checks that it exists
+func resolveRef(ref string) (string, error) {
+	// Try to find the upstream for our target ref
+	upstreamRef := exec.Command("git", "rev-parse", "--abbrev-ref", ref+"@{u}")
+	upstreamRef.Dir = repoRoot
+
+	resolved := ref
+	output, err := upstreamRef.CombinedOutput()
+	if err == nil {
+		resolved = strings.TrimSpace(string(output))
+		if debugFlag {
+			log.Printf("Found upstream for '%s', using '%s'", ref, resolved)
 		}
+	} else if debugFlag {
+		log.Printf("No upstream found for '%s', using local ref", ref)
+	}
+
+	// Validate
+	validateRef := exec.Command("git", "rev-parse", "--verify", "--quiet", resolved)
+	validateRef.Dir = repoRoot
+
+	if out, err := validateRef.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("invalid or non-existent ref %q: %s", resolved, strings.TrimSpace(string(out)))
+	}
+
+	return resolved, nil
+}
+
+// loadCapabilities builds the cluster capabilities from --capabilities-file,
+// --kube-version and --api-versions
+func loadCapabilities() error {
+	var file helm.CapabilitiesFile
+	if capabilitiesFlag != "" {
+		var err error
+		if file, err = helm.LoadCapabilitiesFile(capabilitiesFlag); err != nil {
+			return err
+		}
+	}
+
+	caps, err := helm.Capabilities(file, kubeVersionFlag, apiVersionsFlag)
+	if err != nil {
+		return err
+	}
+	renderCapabilities = caps
+
+	// API versions are only checked against a version that was asked for,
+	// not against Helm's built in default
+	checkKubeVersion = ""
+	if kubeVersionFlag != "" || file.KubeVersion != "" {
+		checkKubeVersion = caps.KubeVersion.Major + "." + caps.KubeVersion.Minor
+	}
+	return nil
+}
 
-		// Create localRender and targetRender outside of goroutines
-		// Create errgroup for chart/kustomization rendering
-		var localRender, targetRender string
-		g := new(errgroup.Group)
+// targetLabel names the side of the diff that is compared against:
+// the target ref, or the values passed with --values-a
+func targetLabel() string {
+	if isDrift() {
+		return driftLabel(valuesAFlag)
+	}
+	return fullRef
+}
+
+// localLabel names the side of the diff that is compared against the
+// target: the working tree, the ref passed with --to, or the values
+// passed with --values-b
+func localLabel() string {
+	if isDrift() {
+		return driftLabel(valuesBFlag)
+	}
+	if toRef != "" {
+		return toRef
+	}
+	return "local"
+}
+
+// renderResult holds the local and target renders for one environment,
+// along with the results of the checks run on them
+type renderResult struct {
+	env environment
+	// path is the rendered directory, relative to the repository root
+	path string
+	// from and to name the two sides in diff headers
+	from   string
+	to     string
+	local  string
+	target string
+	// rawLocal and rawTarget are the renders after secret redaction, but
+	// before filters and ignore rules, so checks see every object and field
+	rawLocal  string
+	rawTarget string
+
+	// filtered counts the objects excluded by the selection filters
+	filtered int
+	// suppressed counts the changed fields removed by ignore rules
+	suppressed int
+	validation []validate.Failure
+	// destructive lists changes that force replacement or risk data loss
+	destructive []destructive.Change
+	// policy lists new violations of the --policy-dir rules
+	policy []policy.Violation
+	// images lists containers whose image changed
+	images []images.Change
+	// capacity lists workloads whose requested capacity changed
+	capacity []capacity.Change
+	// security lists RBAC and network exposure changes to review
+	security []security.Finding
+	// deprecations lists objects using API versions deprecated or removed
+	// in the target Kubernetes version
+	deprecations []deprecation.Finding
+}
+
+// label returns a suffix for error messages identifying the result
+func (r renderResult) label() string {
+	if !multiPath() {
+		return r.env.label()
+	}
+	return fmt.Sprintf(" for '%s'%s", r.path, r.env.label())
+}
+
+// renderEnvironments renders every result for both the local tree and the
+// target work tree. Renders are spread over a worker pool bounded by --jobs.
+func renderEnvironments(ctx context.Context, localRoot, targetRoot string, results []renderResult) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(jobsFlag, 1))
+
+	for i := range results {
+		res := &results[i]
+		localPath := filepath.Join(localRoot, res.path)
+		targetPath := filepath.Join(targetRoot, res.path)
+
+		// Find the values files once, then map them into both trees so
+		// the same files are used on each side
+		located := locateValues(repoRoot, res.path, res.env.values, localRoot, targetRoot)
+		localValuesPaths := valuesPaths(localRoot, located)
+		targetValuesPaths := valuesPaths(targetRoot, located)
 
 		// Render local Chart or Kustomization
 		g.Go(func() error {
-			localRender, err = diff.RenderManifests(localPath, localValuesPaths, debugFlag, updateFlag, releaseNameFlag)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// With --to or --changed, the path may have been removed by
+			// the changes, so it is diffed against an empty render
+			if _, err := os.Stat(localPath); (toRef != "" || changedFlag) && os.IsNotExist(err) {
+				res.local = ""
+				return nil
+			}
+			localRender, err := diff.RenderManifests(localPath, res.env.renderOptions(localValuesPaths))
 			if err != nil {
-				return fmt.Errorf("failed to render path in local ref: %w", err)
+				return fmt.Errorf("failed to render path in local ref%s: %w", res.label(), err)
 			}
+			res.local = localRender
 			return nil
 		})
 
 		// Render target Ref Chart or Kustomization
 		g.Go(func() error {
-			targetRender, err = diff.RenderManifests(targetPath, targetValuesPaths, debugFlag, updateFlag, releaseNameFlag)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// If the path does not exist in the target ref
+			// We can assume it's a new addition and diff against
+			// an empty string instead.
+			if _, err := os.Stat(targetPath); os.IsNotExist(err) {
+				res.target = ""
+				return nil
+			}
+			targetRender, err := diff.RenderManifests(targetPath, res.env.renderOptions(targetValuesPaths))
 			if err != nil {
-				// If the path does not exist in the target ref
-				// We can assume it's a new addition and diff against
-				// an empty string instead.
-				if os.IsNotExist(err) {
-					targetRender = ""
-				} else {
-					return fmt.Errorf("failed to render target ref manifests: %w", err)
-				}
+				return fmt.Errorf("failed to render target ref manifests%s: %w", res.label(), err)
 			}
+			res.target = targetRender
 			return nil
 		})
+	}
+
+	// Ensure all rendering goroutines have finished before creating our diffs
+	return g.Wait()
+}
 
-		// Ensure both rendering goroutines have finished before creating our diff
-		err = g.Wait()
+// printDiff prints the diff for a single render result to stdout
+// and reports whether any differences were found
+func printDiff(res renderResult) (bool, error) {
+	if semanticDiffFlag {
+		// We are using a more complex diff engine (dyff) which is better suited for k8s manifest comparison
+		renderedDiff, err := diff.CreateSemanticDiff(res.target, res.local, res.from, res.to, noColorFlag)
 		if err != nil {
-			return err
+			return false, fmt.Errorf("error creating dyff: %w", err)
 		}
 
-		if semanticDiffFlag {
-			// We are using a more complex diff engine (dyff) which is better suited for k8s manifest comparison
-			renderedDiff, err := diff.CreateSemanticDiff(targetRender, localRender, fmt.Sprintf("%s/%s", fullRef, relativePath), fmt.Sprintf("local/%s", relativePath), noColorFlag)
-			if err != nil {
-				return fmt.Errorf("error creating dyff: %w", err)
-			}
+		if len(renderedDiff.Diffs) == 0 {
+			fmt.Println("\nNo differences found between rendered manifests.")
+			return false, nil
+		}
 
-			if len(renderedDiff.Diffs) == 0 {
-				fmt.Println("\nNo differences found between rendered manifests.")
-				return nil
-			} else {
-				fmt.Printf("\n--- Diff (%s vs. local) ---", fullRef)
-				err := renderedDiff.WriteReport(os.Stdout)
-				if err != nil {
-					return err
-				}
-				// Print summary of changed objects
-				err = diff.PrintChangeSummary(renderedDiff.Report)
-				if err != nil {
-					return fmt.Errorf("error printing summary: %w", err)
-				}
-			}
-		} else {
-			// Generate and Print our simple diff
-			// This is better suited for github comments, or small changes
-			renderedDiff := diff.CreateDiff(targetRender, localRender, fmt.Sprintf("%s/%s", fullRef, relativePath), fmt.Sprintf("local/%s", relativePath))
+		fmt.Printf("\n--- Diff (%s vs. %s) ---", targetLabel(), localLabel())
+		err = renderedDiff.WriteReport(os.Stdout)
+		if err != nil {
+			return true, err
+		}
+		// Print summary of changed objects
+		err = diff.PrintChangeSummary(renderedDiff.Report)
+		if err != nil {
+			return true, fmt.Errorf("error printing summary: %w", err)
+		}
+		return true, nil
+	}
 
-			if renderedDiff == "" {
-				fmt.Println("\nNo differences found between rendered manifests.")
-			} else {
-				fmt.Printf("\n--- Diff (%s vs. local) ---\n", fullRef)
-				fmt.Println(diff.ColorizeDiff(renderedDiff, noColorFlag))
+	// Generate and Print our simple diff
+	// This is better suited for github comments, or small changes
+	renderedDiff := diff.CreateDiff(res.target, res.local, res.from, res.to)
 
-			}
-		}
-		return nil
-	},
+	if renderedDiff == "" {
+		fmt.Println("\nNo differences found between rendered manifests.")
+		return false, nil
+	}
+
+	fmt.Printf("\n--- Diff (%s vs. %s) ---\n", targetLabel(), localLabel())
+	fmt.Println(diff.ColorizeDiff(renderedDiff, noColorFlag))
+	return true, nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -214,8 +546,30 @@ func Execute() {
 	defer cancel()
 
 	err := rootCmd.ExecuteContext(ctx)
-	if err != nil {
-		os.Exit(1)
+	cancel()
+	os.Exit(exitCode(err))
+}
+
+// Exit codes returned by render-diff. These are a contract for CI
+// pipelines and tools wrapping render-diff, so existing values must not change.
+const (
+	exitOK          = 0
+	exitDiff        = 1
+	exitError       = 2
+	exitCheckFailed = 3
+)
+
+// exitCode maps the result of a run to a process exit code
+func exitCode(err error) int {
+	switch {
+	case err != nil:
+		return exitError
+	case checksFailed:
+		return exitCheckFailed
+	case exitCodeFlag && diffFound:
+		return exitDiff
+	default:
+		return exitOK
 	}
 }
 
@@ -223,13 +577,51 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory")
 	rootCmd.PersistentFlags().StringVarP(&gitRefFlag, "ref", "r", "main", "Target Git ref to compare against. Will try to find its remote-tracking branch (e.g., origin/main)")
+	rootCmd.PersistentFlags().StringVarP(&fromFlag, "from", "", "", "Git ref to compare from. Same as --ref")
+	rootCmd.PersistentFlags().StringVarP(&toFlag, "to", "", "", "Git ref to compare to instead of the working tree. Both sides are rendered from temporary work trees")
+	rootCmd.PersistentFlags().BoolVarP(&mergeBaseFlag, "merge-base", "", false, "Compare against the merge base of HEAD (or --to) and the target ref, like a three-dot diff")
 	rootCmd.PersistentFlags().StringSliceVarP(&valuesFlag, "values", "f", []string{}, "Path to an additional values file (can be specified multiple times)")
 	rootCmd.PersistentFlags().StringVarP(&releaseNameFlag, "release-name", "", "", "Helm release name to use when rendering templates. Defaults to chart name")
+	rootCmd.PersistentFlags().StringArrayVarP(&setFlag, "set", "", []string{}, "Set values on the command line, e.g. image.tag=v1.2.3 (can be specified multiple times or separate values with commas)")
+	rootCmd.PersistentFlags().StringArrayVarP(&setStringFlag, "set-string", "", []string{}, "Set STRING values on the command line (can be specified multiple times or separate values with commas)")
+	rootCmd.PersistentFlags().StringArrayVarP(&setFileFlag, "set-file", "", []string{}, "Set values from files on the command line, e.g. key=path (can be specified multiple times or separate values with commas)")
+	rootCmd.PersistentFlags().StringArrayVarP(&setJSONFlag, "set-json", "", []string{}, "Set JSON values on the command line, e.g. key=jsonval (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringVarP(&releaseNamespaceFlag, "release-namespace", "", "", "Helm release namespace to use when rendering templates. Defaults to 'default'")
+	rootCmd.PersistentFlags().StringVarP(&kubeVersionFlag, "kube-version", "", "", "Kubernetes version used for .Capabilities.KubeVersion and to check for deprecated API versions, e.g. 1.30. Defaults to Helm's built in version")
+	rootCmd.PersistentFlags().StringSliceVarP(&apiVersionsFlag, "api-versions", "", []string{}, "Additional API versions used for .Capabilities.APIVersions (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringVarP(&capabilitiesFlag, "capabilities-file", "", "", "YAML file with the kubeVersion and apiVersions of the target cluster")
 	rootCmd.PersistentFlags().BoolVarP(&updateFlag, "update", "u", false, "Update helm chart dependencies. Required if lockfile does not match dependencies")
+	rootCmd.PersistentFlags().BoolVarP(&changedFlag, "changed", "", false, "Diff every chart and kustomization below --path that is affected by the changes against the target ref")
+	rootCmd.PersistentFlags().StringVarP(&appFlag, "app", "", "", "Argo CD Application or ApplicationSet manifest, or a directory of them. Renders each Application with the settings in spec.source.helm")
+	rootCmd.PersistentFlags().StringSliceVarP(&targetFlag, "target", "t", []string{}, "Target from the .render-diff.yaml file in the repository root to render (can be specified multiple times)")
+	rootCmd.PersistentFlags().BoolVarP(&allFlag, "all", "", false, "Render every target in the .render-diff.yaml file in the repository root")
+	rootCmd.PersistentFlags().StringSliceVarP(&valuesAFlag, "values-a", "", []string{}, "Values files for the first side of an environment drift comparison. Compares two values sets at the same ref without git")
+	rootCmd.PersistentFlags().StringSliceVarP(&valuesBFlag, "values-b", "", []string{}, "Values files for the second side of an environment drift comparison")
+	rootCmd.PersistentFlags().StringArrayVarP(&envFlag, "env", "e", []string{}, "Environment to render as name=values-a.yaml[,values-b.yaml] (can be specified multiple times)")
+	rootCmd.PersistentFlags().BoolVarP(&matrixFlag, "matrix", "m", false, "Render every values-<env>.yaml file found in the chart directory as its own environment")
+	rootCmd.PersistentFlags().IntVarP(&jobsFlag, "jobs", "j", 4, "Maximum number of renders to run in parallel")
 	rootCmd.PersistentFlags().BoolVarP(&semanticDiffFlag, "semantic", "s", false, "Enable semantic diffing of k8s manifests (using dyff)")
+	rootCmd.PersistentFlags().BoolVarP(&normalizeFlag, "normalize", "", false, "Sort objects and keys and drop comments before diffing, so moved resources and key order changes are not reported")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", outputText, "Output format. One of: text, json, markdown")
+	rootCmd.PersistentFlags().StringVarP(&fullDiffFileFlag, "full-diff-file", "", "render-diff.diff", "File the full diff is written to when markdown output is truncated")
+	rootCmd.PersistentFlags().BoolVarP(&exitCodeFlag, "exit-code", "", false, "Exit with 1 if there were differences and 0 if there were none, like 'git diff --exit-code'")
+	rootCmd.PersistentFlags().BoolVarP(&validateFlag, "validate", "", false, "Validate both renders against JSON schemas and report objects that newly fail validation")
+	rootCmd.PersistentFlags().BoolVarP(&failDestructiveFlag, "fail-on-destructive", "", false, "Exit with 3 if a change requires replacing an object or risks data loss")
+	rootCmd.PersistentFlags().StringVarP(&policyDirFlag, "policy-dir", "", "", "Directory of policy files to check the local render and its changes against")
+	rootCmd.PersistentFlags().StringVarP(&failPolicyFlag, "fail-on-policy", "", "", "Exit with 3 if a new policy violation has this severity or higher. One of: info, warning, error")
+	rootCmd.PersistentFlags().StringVarP(&schemaDirFlag, "schema-dir", "", "", "Schema directory in the {group}/{kind}_{version}.json layout. Defaults to crdSchemas in the repository root")
+	rootCmd.PersistentFlags().StringSliceVarP(&includeKindFlag, "include-kind", "", []string{}, "Only diff objects of these kinds. Accepts glob patterns (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringSliceVarP(&excludeKindFlag, "exclude-kind", "", []string{}, "Do not diff objects of these kinds. Accepts glob patterns (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringSliceVarP(&nameFlag, "name", "", []string{}, "Only diff objects with these names. Accepts glob patterns (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringSliceVarP(&namespaceFlag, "namespace", "", []string{}, "Only diff objects in these namespaces. Accepts glob patterns (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringArrayVarP(&ignoreFlag, "ignore", "", []string{}, "Field to ignore as a go-patch or JSON path, optionally scoped as Kind[/name]:path (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringVarP(&ignoreFileFlag, "ignore-file", "", "", "YAML file with ignore rules")
+	rootCmd.PersistentFlags().BoolVarP(&showSecretsFlag, "show-secrets", "", false, "Show Secret data and stringData values instead of redacting them. Do not use in CI. Redacted values are hashed with a random key per run, unless "+redact.KeyEnv+" is set")
 	rootCmd.PersistentFlags().BoolVarP(&noColorFlag, "no-color", "", false, "Output in plain style without any highlighting")
 	rootCmd.PersistentFlags().BoolVarP(&debugFlag, "debug", "d", false, "Enable verbose logging for debugging")
 
+	rootCmd.MarkFlagsMutuallyExclusive("ref", "from")
+
 	rootCmd.Flags().SortFlags = false
 	rootCmd.PersistentFlags().SortFlags = false
 }
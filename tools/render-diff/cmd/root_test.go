@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/spf13/pflag"
 )
 
 // resetFlags resets all package-level flag variables to their defaults.
@@ -15,12 +18,57 @@ func resetFlags() {
 	// Reset to default values from init()
 	renderPathFlag = "."
 	gitRefFlag = "HEAD"
+	fromFlag = ""
+	toFlag = ""
+	mergeBaseFlag = false
+	valuesAFlag = []string{}
+	valuesBFlag = []string{}
+	changedFlag = false
+	appFlag = ""
+	targetFlag = []string{}
+	allFlag = false
+	releaseNamespaceFlag = ""
+	setFlag = []string{}
+	setStringFlag = []string{}
+	setFileFlag = []string{}
+	setJSONFlag = []string{}
+	kubeVersionFlag = ""
+	apiVersionsFlag = []string{}
+	capabilitiesFlag = ""
 	valuesFlag = []string{}
+	outputFlag = outputText
+	normalizeFlag = false
+	failDestructiveFlag = false
+	policyDirFlag = ""
+	failPolicyFlag = ""
+	showSecretsFlag = false
 	debugFlag = false
+	matrixFlag = false
+	includeKindFlag = []string{}
+	excludeKindFlag = []string{}
+	nameFlag = []string{}
+	namespaceFlag = []string{}
+	ignoreFlag = []string{}
+	ignoreFileFlag = ""
+	validateFlag = false
+	schemaDirFlag = ""
+	envFlag = []string{}
+	checkFlag = false
+	snapshotDirFlag = ""
+
+	// Flags set by an earlier run would otherwise still count as changed
+	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+	snapshotCmd.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
 
 	// Reset state variables set by PreRunE
 	repoRoot = ""
 	fullRef = ""
+	toRef = ""
+	projectTargets = nil
+	renderCapabilities = nil
+	checkKubeVersion = ""
+	diffFound = false
+	checksFailed = false
 }
 
 // executeCommand is a helper to run the rootCmd with a given context and args.
@@ -98,6 +146,23 @@ func TestRootCmd(t *testing.T) {
 		}
 	})
 
+	t.Run("PreRunE failure (policy flags)", func(t *testing.T) {
+		testCases := []struct {
+			args    []string
+			wantErr string
+		}{
+			{args: []string{"--fail-on-policy", "error"}, wantErr: "--fail-on-policy requires --policy-dir"},
+			{args: []string{"--policy-dir", ".", "--fail-on-policy", "fatal"}, wantErr: "invalid severity 'fatal'"},
+		}
+
+		for _, tc := range testCases {
+			_, _, err := executeCommand(context.Background(), tc.args...)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("executeCommand(%v) error = %v, want it to contain %q", tc.args, err, tc.wantErr)
+			}
+		}
+	})
+
 	t.Run("RunE failure (path outside repo)", func(t *testing.T) {
 		// We use a path that is guaranteed to be outside the repo
 		path := os.TempDir()
@@ -114,3 +179,66 @@ func TestRootCmd(t *testing.T) {
 		}
 	})
 }
+
+func TestExitCode(t *testing.T) {
+	t.Cleanup(func() {
+		exitCodeFlag, diffFound, checksFailed = false, false, false
+	})
+
+	testCases := []struct {
+		name         string
+		exitCode     bool
+		diffFound    bool
+		checksFailed bool
+		err          error
+		want         int
+	}{
+		{name: "No diff", want: exitOK},
+		{name: "Diff without --exit-code", diffFound: true, want: exitOK},
+		{name: "No diff with --exit-code", exitCode: true, want: exitOK},
+		{name: "Diff with --exit-code", exitCode: true, diffFound: true, want: exitDiff},
+		{name: "Error", err: errors.New("render failed"), want: exitError},
+		{name: "Error with --exit-code and diff", exitCode: true, diffFound: true, err: errors.New("git failed"), want: exitError},
+		{name: "Failed check", checksFailed: true, want: exitCheckFailed},
+		{name: "Failed check with diff", exitCode: true, diffFound: true, checksFailed: true, want: exitCheckFailed},
+		{name: "Error and failed check", checksFailed: true, err: errors.New("render failed"), want: exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exitCodeFlag, diffFound, checksFailed = tc.exitCode, tc.diffFound, tc.checksFailed
+			if got := exitCode(tc.err); got != tc.want {
+				t.Errorf("exitCode() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadCapabilitiesCheckKubeVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		kubeVersion string
+		file        string
+		want        string
+	}{
+		{name: "Helm default is not checked", want: ""},
+		{name: "Flag", kubeVersion: "v1.30.2", want: "1.30"},
+		{name: "Capabilities file", file: "../internal/helm/testdata/gke.yaml", want: "1.30"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetFlags()
+			defer resetFlags()
+			kubeVersionFlag = tc.kubeVersion
+			capabilitiesFlag = tc.file
+
+			if err := loadCapabilities(); err != nil {
+				t.Fatalf("loadCapabilities() failed: %v", err)
+			}
+			if checkKubeVersion != tc.want {
+				t.Errorf("checkKubeVersion = %q, want %q", checkKubeVersion, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/redact"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultSnapshotDir is the snapshot directory used without --snapshot-dir,
+// inside the chart or kustomization directory like Jest's __snapshots__
+const defaultSnapshotDir = "__snapshots__"
+
+// defaultSnapshotName names the snapshot of the unnamed environment that is
+// rendered without --env or --matrix
+const defaultSnapshotName = "default"
+
+var (
+	checkFlag       bool
+	snapshotDirFlag string
+)
+
+// snapshotCmd writes or checks the snapshots of a chart or kustomization
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Write the normalized render of each environment to a snapshot directory, or check it with --check",
+	Long: `snapshot renders the chart or kustomization at --path for each environment and writes the
+normalized render to <snapshot-dir>/<env>.yaml. Commit the snapshots with the chart.
+
+With --check the renders are compared against the committed snapshots instead, without
+writing them. Outdated or missing snapshots are printed as a diff and render-diff exits
+with 3. No git ref or work tree is needed.
+
+Environments are selected with --env and --matrix like the diff. Without them a single
+snapshot named default.yaml is written using --values.`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		for _, name := range []string{"ref", "from", "to", "merge-base", "changed", "app", "target", "all", "values-a", "values-b", "semantic", "output", "full-diff-file", "exit-code", "validate", "fail-on-destructive", "policy-dir", "fail-on-policy", "schema-dir", "show-secrets"} {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--%s cannot be used with snapshot", name)
+			}
+		}
+		if err := selector().Validate(); err != nil {
+			return err
+		}
+		return loadCapabilities()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSnapshot(cmd.Context())
+	},
+}
+
+// snapshotName returns the file name of the snapshot of an environment
+func snapshotName(env environment) (string, error) {
+	if env.name == "" {
+		return defaultSnapshotName + ".yaml", nil
+	}
+	if strings.ContainsAny(env.name, `/\`) || env.name == "." || env.name == ".." {
+		return "", fmt.Errorf("environment '%s' cannot be used as a snapshot file name", env.name)
+	}
+	return env.name + ".yaml", nil
+}
+
+// runSnapshot renders every environment of the chart at renderPathFlag
+// and writes the renders as snapshots, or compares them with --check
+func runSnapshot(ctx context.Context) error {
+	chartPath, err := filepath.Abs(renderPathFlag)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+	}
+
+	dir := snapshotDirFlag
+	if dir == "" {
+		dir = filepath.Join(renderPathFlag, defaultSnapshotDir)
+	}
+
+	environments, err := resolveEnvironments(chartPath)
+	if err != nil {
+		return err
+	}
+
+	// The snapshot file is the target side of each result, so --check
+	// diffs read like a diff against a ref
+	results := make([]renderResult, len(environments))
+	for i, env := range environments {
+		name, err := snapshotName(env)
+		if err != nil {
+			return err
+		}
+		results[i] = renderResult{
+			env:  env,
+			from: filepath.Join(dir, name),
+			to:   fmt.Sprintf("%s (%s)", renderPathFlag, strings.TrimSuffix(name, ".yaml")),
+		}
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(jobsFlag, 1))
+	for i := range results {
+		res := &results[i]
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			render, err := diff.RenderManifests(chartPath, res.env.renderOptions(driftValues(chartPath, res.env.values)))
+			if err != nil {
+				return fmt.Errorf("failed to render%s: %w", res.label(), err)
+			}
+			// Snapshots are committed and compared across runs, so Secret
+			// values are masked rather than hashed with a per-run key
+			if res.local, err = redact.Mask(render); err != nil {
+				return fmt.Errorf("failed to redact secrets%s: %w", res.label(), err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// Snapshots are always normalized, so reordered keys and comments
+	// do not fail --check
+	if err := prepareResults(results); err != nil {
+		return err
+	}
+	for i := range results {
+		if results[i].local, err = manifest.Normalize(results[i].local); err != nil {
+			return fmt.Errorf("failed to normalize render%s: %w", results[i].label(), err)
+		}
+	}
+
+	if checkFlag {
+		return checkSnapshots(results)
+	}
+	return writeSnapshots(dir, results)
+}
+
+// writeSnapshots writes the render of every result to its snapshot file
+func writeSnapshots(dir string, results []renderResult) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	for _, res := range results {
+		if err := os.WriteFile(res.from, []byte(res.local), 0o644); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+		log.Printf("Wrote snapshot %s", res.from)
+	}
+
+	return nil
+}
+
+// checkSnapshots diffs the render of every result against its snapshot
+// file. Outdated and missing snapshots mark the run as failed through
+// checksFailed.
+func checkSnapshots(results []renderResult) error {
+	outdated := 0
+	for _, res := range results {
+		b, err := os.ReadFile(res.from)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if err != nil {
+			fmt.Printf("\nSnapshot %s is missing.\n", res.from)
+		}
+
+		renderedDiff := diff.CreateDiff(string(b), res.local, res.from, res.to)
+		if renderedDiff == "" {
+			continue
+		}
+
+		outdated++
+		fmt.Printf("\n--- Diff (%s vs. %s) ---\n", res.from, res.to)
+		fmt.Println(diff.ColorizeDiff(renderedDiff, noColorFlag))
+	}
+
+	if outdated > 0 {
+		checksFailed = true
+		fmt.Printf("\n%s out of date. Run 'render-diff snapshot' to update.\n", snapshotCount(outdated))
+		return nil
+	}
+
+	fmt.Printf("\n%s up to date.\n", snapshotCount(len(results)))
+	return nil
+}
+
+// snapshotCount returns "1 snapshot is" or "n snapshots are"
+func snapshotCount(n int) string {
+	if n == 1 {
+		return "1 snapshot is"
+	}
+	return fmt.Sprintf("%d snapshots are", n)
+}
+
+func init() {
+	snapshotCmd.Flags().BoolVarP(&checkFlag, "check", "", false, "Compare the renders against the snapshots instead of writing them. Exits with 3 if a snapshot is out of date or missing")
+	snapshotCmd.Flags().StringVarP(&snapshotDirFlag, "snapshot-dir", "", "", "Directory the snapshots are written to. Defaults to __snapshots__ in the chart or kustomization directory")
+
+	rootCmd.AddCommand(snapshotCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	path := "../examples/helm/helloWorld"
+
+	t.Run("Writes and checks snapshots", func(t *testing.T) {
+		dir := t.TempDir()
+
+		_, stderr, err := executeCommand(context.Background(), "snapshot", "--path", path, "--snapshot-dir", dir)
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		snapshot, err := os.ReadFile(filepath.Join(dir, "default.yaml"))
+		if err != nil {
+			t.Fatalf("Expected default.yaml to be written: %v", err)
+		}
+		if !strings.Contains(string(snapshot), "kind: Deployment") {
+			t.Errorf("Expected the render in the snapshot, got:\n%s", snapshot)
+		}
+
+		stdout, stderr, err := executeCommand(context.Background(), "snapshot", "--path", path, "--snapshot-dir", dir, "--check")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		if checksFailed {
+			t.Errorf("Expected the snapshot to match, got:\n%s", stdout)
+		}
+
+		outdated := strings.Replace(string(snapshot), "kind: Deployment", "kind: StatefulSet", 1)
+		if err := os.WriteFile(filepath.Join(dir, "default.yaml"), []byte(outdated), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		stdout, stderr, err = executeCommand(context.Background(), "snapshot", "--path", path, "--snapshot-dir", dir, "--check", "--no-color")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		if !checksFailed {
+			t.Error("Expected an outdated snapshot to fail the check")
+		}
+		if !strings.Contains(stdout, "+kind: Deployment") || !strings.Contains(stdout, "1 snapshot is out of date") {
+			t.Errorf("Expected a diff against the snapshot, got:\n%s", stdout)
+		}
+	})
+
+	t.Run("Writes a snapshot per environment", func(t *testing.T) {
+		dir := t.TempDir()
+
+		_, stderr, err := executeCommand(context.Background(), "snapshot", "--path", path, "--snapshot-dir", dir, "--matrix")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "dev.yaml")); err != nil {
+			t.Errorf("Expected dev.yaml to be written: %v", err)
+		}
+	})
+
+	t.Run("Missing snapshot fails the check", func(t *testing.T) {
+		stdout, _, err := executeCommand(context.Background(), "snapshot", "--path", path, "--snapshot-dir", t.TempDir(), "--check")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v", err)
+		}
+		if !checksFailed || !strings.Contains(stdout, "default.yaml is missing") {
+			t.Errorf("Expected a missing snapshot to fail the check, got:\n%s", stdout)
+		}
+	})
+
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "Rejects --ref", args: []string{"--ref", "HEAD"}, wantErr: "--ref cannot be used with snapshot"},
+		{name: "Rejects --output", args: []string{"--output", "json"}, wantErr: "--output cannot be used with snapshot"},
+		{name: "Rejects --show-secrets", args: []string{"--show-secrets"}, wantErr: "--show-secrets cannot be used with snapshot"},
+		{name: "Rejects environment paths", args: []string{"--env", "../prod=values.yaml"}, wantErr: "cannot be used as a snapshot file name"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := executeCommand(context.Background(), append([]string{"snapshot", "--path", path, "--snapshot-dir", t.TempDir()}, tc.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
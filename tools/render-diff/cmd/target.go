@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// isTargets reports whether targets from the project file were requested
+func isTargets() bool {
+	return len(targetFlag) > 0 || allFlag
+}
+
+// loadTargets reads the targets selected with --target or --all from the
+// project file in the repository root. The output format of the targets
+// is used unless --output is set.
+func loadTargets(cmd *cobra.Command) error {
+	c, err := config.Load(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	projectTargets = c.Targets
+	if !allFlag {
+		projectTargets, err = c.Select(targetFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flags().Changed("output") {
+		return nil
+	}
+	output := ""
+	for _, t := range projectTargets {
+		if t.Output == "" || t.Output == output {
+			continue
+		}
+		if output != "" {
+			return fmt.Errorf("targets use different output formats (%s and %s), select one with --output", output, t.Output)
+		}
+		output = t.Output
+	}
+	if output != "" {
+		outputFlag = output
+	}
+	return validateOutputFlag()
+}
+
+// targetEnvironments returns the path and render settings of each selected
+// target. Files passed with --values are loaded before the target's own.
+func targetEnvironments() ([]string, []environment, error) {
+	paths := make([]string, len(projectTargets))
+	environments := make([]environment, len(projectTargets))
+	for i, t := range projectTargets {
+		rules, err := t.IgnoreRules()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		paths[i] = filepath.FromSlash(t.Path)
+		environments[i] = environment{
+			name:        t.Name,
+			values:      append(append([]string{}, valuesFlag...), t.Values...),
+			releaseName: t.ReleaseName,
+			namespace:   t.Namespace,
+			ignore:      rules,
+		}
+	}
+
+	log.Printf("Rendering %d targets from %s", len(projectTargets), config.FileName)
+	return paths, environments, nil
+}
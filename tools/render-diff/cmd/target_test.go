@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/config"
+)
+
+func TestLoadTargets(t *testing.T) {
+	root := t.TempDir()
+	project := `
+targets:
+  - name: web-prod
+    path: charts/web
+    values: [values-prod.yaml]
+    ignore: ["Deployment:/spec/replicas"]
+    output: markdown
+  - name: web-dev
+    path: charts/web
+  - name: api-dev
+    path: charts/api
+    output: json
+`
+	if err := os.WriteFile(filepath.Join(root, config.FileName), []byte(project), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(resetFlags)
+
+	testCases := []struct {
+		name        string
+		args        []string
+		wantTargets []string
+		wantOutput  string
+		wantErr     string
+	}{
+		{
+			name:        "Target output format",
+			args:        []string{"--target", "web-prod"},
+			wantTargets: []string{"web-prod"},
+			wantOutput:  outputMarkdown,
+		},
+		{
+			name:        "Targets without an output format",
+			args:        []string{"--target", "web-dev,web-prod"},
+			wantTargets: []string{"web-dev", "web-prod"},
+			wantOutput:  outputMarkdown,
+		},
+		{
+			name:        "Flag overrides target output",
+			args:        []string{"--target", "web-prod", "--output", "json"},
+			wantTargets: []string{"web-prod"},
+			wantOutput:  outputJSON,
+		},
+		{
+			name:    "Conflicting output formats",
+			args:    []string{"--all"},
+			wantErr: "different output formats",
+		},
+		{
+			name:        "All targets with --output",
+			args:        []string{"--all", "--output", "text"},
+			wantTargets: []string{"web-prod", "web-dev", "api-dev"},
+			wantOutput:  outputText,
+		},
+		{
+			name:    "Unknown target",
+			args:    []string{"--target", "web-stage"},
+			wantErr: "unknown target 'web-stage'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetFlags()
+			if err := rootCmd.ParseFlags(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			repoRoot = root
+
+			err := loadTargets(rootCmd)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("loadTargets() error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadTargets() failed: %v", err)
+			}
+
+			var names []string
+			for _, target := range projectTargets {
+				names = append(names, target.Name)
+			}
+			if !reflect.DeepEqual(names, tc.wantTargets) {
+				t.Errorf("targets = %v, want %v", names, tc.wantTargets)
+			}
+			if outputFlag != tc.wantOutput {
+				t.Errorf("output = %q, want %q", outputFlag, tc.wantOutput)
+			}
+		})
+	}
+
+	t.Run("Target environments", func(t *testing.T) {
+		resetFlags()
+		valuesFlag = []string{"common.yaml"}
+		projectTargets = []config.Target{{Name: "web-prod", Path: "charts/web", Values: []string{"values-prod.yaml"}, ReleaseName: "web", Ignore: []string{"Deployment:/spec/replicas"}}}
+
+		paths, environments, err := targetEnvironments()
+		if err != nil {
+			t.Fatalf("targetEnvironments() failed: %v", err)
+		}
+		if !reflect.DeepEqual(paths, []string{filepath.FromSlash("charts/web")}) {
+			t.Errorf("paths = %v", paths)
+		}
+		env := environments[0]
+		if env.name != "web-prod" || env.releaseName != "web" || !reflect.DeepEqual(env.values, []string{"common.yaml", "values-prod.yaml"}) || len(env.ignore) != 1 {
+			t.Errorf("environment = %+v", env)
+		}
+	})
+
+	t.Run("Rejects --path", func(t *testing.T) {
+		_, _, err := executeCommand(context.Background(), "--target", "web-prod", "--path", ".")
+		if err == nil || !strings.Contains(err.Error(), "--path cannot be used with --target/--all") {
+			t.Errorf("expected an error for --path, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// locateValues finds the values files passed on the command line for the
+// chart in chartDir, relative to repoRoot. Relative paths are tried against
+// the chart directory first, then the directory render-diff was started
+// from and finally the repository root. A file is found if it exists in any
+// of roots, the trees being rendered. Files in the repository are returned
+// relative to repoRoot, so valuesPaths can map them into each tree, and
+// files outside of it are returned as absolute paths. Files that are not
+// found keep the chart relative path, so Helm reports them as missing.
+func locateValues(repoRoot, chartDir string, values []string, roots ...string) []string {
+	repoRoot = resolveSymlinks(repoRoot)
+
+	located := make([]string, len(values))
+	for i, v := range values {
+		var candidates []string
+		if filepath.IsAbs(v) {
+			candidates = []string{v}
+		} else {
+			candidates = []string{filepath.Join(repoRoot, chartDir, v)}
+			if abs, err := filepath.Abs(v); err == nil {
+				candidates = append(candidates, abs)
+			}
+			candidates = append(candidates, filepath.Join(repoRoot, v))
+		}
+
+		located[i] = filepath.Join(chartDir, v)
+		if filepath.IsAbs(v) {
+			located[i] = v
+		}
+		for _, candidate := range candidates {
+			if p, ok := locateFile(repoRoot, resolveSymlinks(candidate), roots); ok {
+				located[i] = p
+				break
+			}
+		}
+	}
+	return located
+}
+
+// locateFile reports whether the file at abs exists. Files in the
+// repository are looked up in every root and returned relative to
+// repoRoot, other files are returned unchanged.
+func locateFile(repoRoot, abs string, roots []string) (string, bool) {
+	rel, err := filepath.Rel(repoRoot, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs, fileExists(abs)
+	}
+
+	for _, root := range roots {
+		if fileExists(filepath.Join(root, rel)) {
+			return rel, true
+		}
+	}
+	return rel, false
+}
+
+// valuesPaths maps values files returned by locateValues into root
+func valuesPaths(root string, located []string) []string {
+	paths := make([]string, len(located))
+	for i, p := range located {
+		if filepath.IsAbs(p) {
+			paths[i] = p
+		} else {
+			paths[i] = filepath.Join(root, p)
+		}
+	}
+	return paths
+}
+
+// resolveSymlinks returns p with symlinks resolved, or p unchanged if it
+// does not exist. git reports the repository root with symlinks resolved.
+func resolveSymlinks(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	return p
+}
+
+// fileExists reports whether p exists and is not a directory
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
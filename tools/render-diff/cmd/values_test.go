@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLocateValues(t *testing.T) {
+	repo := resolveSymlinks(t.TempDir())
+	worktree := t.TempDir()
+	external := filepath.Join(resolveSymlinks(t.TempDir()), "secrets.yaml")
+
+	for _, f := range []string{
+		filepath.Join(repo, "charts/web/values-dev.yaml"),
+		filepath.Join(repo, "charts/web/values.yaml"),
+		filepath.Join(repo, "common/values-prod.yaml"),
+		filepath.Join(repo, "envs/stage/values.yaml"),
+		filepath.Join(repo, "envs/stage/values-stage.yaml"),
+		filepath.Join(worktree, "charts/web/values-old.yaml"),
+		external,
+	} {
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte{}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Relative paths are also tried against the directory render-diff runs in
+	t.Chdir(filepath.Join(repo, "envs/stage"))
+
+	testCases := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{
+			name:   "Relative to the chart",
+			values: []string{"values-dev.yaml", "../../common/values-prod.yaml"},
+			want:   []string{"charts/web/values-dev.yaml", "common/values-prod.yaml"},
+		},
+		{
+			name:   "Only in the target tree",
+			values: []string{"values-old.yaml"},
+			want:   []string{"charts/web/values-old.yaml"},
+		},
+		{
+			name:   "Relative to the current directory",
+			values: []string{"values-stage.yaml", "values.yaml"},
+			// values.yaml also exists next to the chart, which takes precedence
+			want: []string{"envs/stage/values-stage.yaml", "charts/web/values.yaml"},
+		},
+		{
+			name:   "Relative to the repository root",
+			values: []string{"envs/stage/values.yaml"},
+			want:   []string{"envs/stage/values.yaml"},
+		},
+		{
+			name:   "Absolute path in the repository",
+			values: []string{filepath.Join(repo, "common/values-prod.yaml")},
+			want:   []string{"common/values-prod.yaml"},
+		},
+		{
+			name:   "Outside of the repository",
+			values: []string{external},
+			want:   []string{external},
+		},
+		{
+			name:   "Missing file",
+			values: []string{"values-missing.yaml"},
+			want:   []string{"charts/web/values-missing.yaml"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := locateValues(repo, "charts/web", tc.values, repo, worktree)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("locateValues() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Mapped into each tree", func(t *testing.T) {
+		located := locateValues(repo, "charts/web", []string{"values-dev.yaml", external}, repo, worktree)
+		got := valuesPaths(worktree, located)
+		want := []string{filepath.Join(worktree, "charts/web/values-dev.yaml"), external}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("valuesPaths() = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,332 @@
+// Package argocd reads Argo CD Application and ApplicationSet manifests
+// and derives the settings needed to render their sources locally
+package argocd
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// App holds the render settings of one source of an Argo CD Application
+type App struct {
+	// Name is the Application name, suffixed with the source index
+	// for Applications with more than one rendered source
+	Name string
+	// Path is the source path, relative to the repository root
+	Path string
+	// ValueFiles are relative to Path
+	ValueFiles  []string
+	ReleaseName string
+	Namespace   string
+	// Values holds the inline values and valuesObject, in that order
+	Values []map[string]any
+	// Set and SetString hold the helm parameters as "name=value" strings
+	Set       []string
+	SetString []string
+}
+
+// application holds the fields of an Application spec used for rendering
+type application struct {
+	Source      *source   `yaml:"source"`
+	Sources     []*source `yaml:"sources"`
+	Destination struct {
+		Namespace string `yaml:"namespace"`
+	} `yaml:"destination"`
+}
+
+type source struct {
+	RepoURL string      `yaml:"repoURL"`
+	Path    string      `yaml:"path"`
+	Chart   string      `yaml:"chart"`
+	Ref     string      `yaml:"ref"`
+	Helm    *helmSource `yaml:"helm"`
+}
+
+type helmSource struct {
+	ReleaseName  string         `yaml:"releaseName"`
+	ValueFiles   []string       `yaml:"valueFiles"`
+	Values       string         `yaml:"values"`
+	ValuesObject map[string]any `yaml:"valuesObject"`
+	Parameters   []struct {
+		Name        string `yaml:"name"`
+		Value       string `yaml:"value"`
+		ForceString bool   `yaml:"forceString"`
+	} `yaml:"parameters"`
+	FileParameters []struct {
+		Name string `yaml:"name"`
+	} `yaml:"fileParameters"`
+}
+
+// Load reads the Applications in a manifest file, or in every YAML file
+// below a directory. ApplicationSets are expanded using their list
+// generators. Other objects in the files are ignored.
+func Load(p string) ([]App, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Argo CD manifests: %w", err)
+	}
+
+	files := []string{p}
+	if info.IsDir() {
+		files, err = yamlFiles(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var apps []App
+	seen := make(map[string]string)
+	for _, file := range files {
+		fileApps, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range fileApps {
+			if other, ok := seen[app.Name]; ok {
+				return nil, fmt.Errorf("application '%s' is defined in both %s and %s", app.Name, other, file)
+			}
+			seen[app.Name] = file
+			apps = append(apps, app)
+		}
+	}
+
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no Argo CD Applications found in '%s'", p)
+	}
+	return apps, nil
+}
+
+// yamlFiles lists the YAML files below dir in lexical order
+func yamlFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if ext := filepath.Ext(p); !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Argo CD manifests: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// loadFile reads the Applications and ApplicationSets in one file
+func loadFile(file string) ([]App, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Argo CD manifest: %w", err)
+	}
+
+	docs, err := manifest.Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	var apps []App
+	for _, doc := range docs {
+		if doc.ID.Group != "argoproj.io" {
+			continue
+		}
+
+		var fileApps []App
+		switch doc.ID.Kind {
+		case "Application":
+			fileApps, err = fromApplication(doc.ID.Name, doc.Node.Content[0])
+		case "ApplicationSet":
+			fileApps, err = fromApplicationSet(doc.ID.Name, doc.Node.Content[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in %s: %w", doc.ID, file, err)
+		}
+		apps = append(apps, fileApps...)
+	}
+	return apps, nil
+}
+
+// fromApplication derives the render settings of an Application
+func fromApplication(name string, node *yaml.Node) ([]App, error) {
+	var spec application
+	if specNode := manifest.Lookup(node, "spec"); specNode != nil {
+		if err := specNode.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("failed to decode spec: %w", err)
+		}
+	}
+
+	sources := spec.Sources
+	if spec.Source != nil {
+		sources = []*source{spec.Source}
+	}
+
+	// Sources with a ref only provide value files to other sources
+	refs := make(map[string]bool)
+	var rendered []*source
+	for _, src := range sources {
+		switch {
+		case src.Ref != "":
+			refs[src.Ref] = true
+		case src.Chart != "":
+			log.Printf("Warning: skipping chart repository source '%s' of application '%s', only sources with a path can be rendered", src.Chart, name)
+		case src.Path != "":
+			rendered = append(rendered, src)
+		}
+	}
+	if len(rendered) == 0 {
+		return nil, fmt.Errorf("application '%s' has no source with a path", name)
+	}
+
+	apps := make([]App, 0, len(rendered))
+	for i, src := range rendered {
+		app := App{
+			Name:        name,
+			Path:        path.Clean(strings.TrimPrefix(src.Path, "/")),
+			ReleaseName: name,
+			Namespace:   spec.Destination.Namespace,
+		}
+		if len(rendered) > 1 {
+			app.Name = fmt.Sprintf("%s[%d]", name, i)
+		}
+
+		if err := app.applyHelm(src.Helm, refs); err != nil {
+			return nil, fmt.Errorf("application '%s': %w", name, err)
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// applyHelm copies the helm settings of a source onto the app.
+// Argo CD uses the Application name when no release name is set.
+func (a *App) applyHelm(h *helmSource, refs map[string]bool) error {
+	if h == nil {
+		return nil
+	}
+
+	if h.ReleaseName != "" {
+		a.ReleaseName = h.ReleaseName
+	}
+
+	for _, file := range h.ValueFiles {
+		// "$ref/path" points into the source with that ref. Only sources
+		// from the same repository can be rendered, so the path is
+		// relative to the repository root.
+		if ref, rest, found := strings.Cut(file, "/"); found && strings.HasPrefix(ref, "$") {
+			if !refs[strings.TrimPrefix(ref, "$")] {
+				return fmt.Errorf("value file '%s' uses unknown source ref '%s'", file, ref)
+			}
+			rel, err := filepath.Rel(filepath.FromSlash(a.Path), filepath.FromSlash(rest))
+			if err != nil {
+				return fmt.Errorf("failed to resolve value file '%s': %w", file, err)
+			}
+			file = rel
+		}
+		a.ValueFiles = append(a.ValueFiles, file)
+	}
+
+	if h.Values != "" {
+		var values map[string]any
+		if err := yaml.Unmarshal([]byte(h.Values), &values); err != nil {
+			return fmt.Errorf("failed to parse helm values: %w", err)
+		}
+		a.Values = append(a.Values, values)
+	}
+	if h.ValuesObject != nil {
+		a.Values = append(a.Values, h.ValuesObject)
+	}
+
+	for _, p := range h.Parameters {
+		if p.ForceString {
+			a.SetString = append(a.SetString, p.Name+"="+p.Value)
+		} else {
+			a.Set = append(a.Set, p.Name+"="+p.Value)
+		}
+	}
+	for _, p := range h.FileParameters {
+		log.Printf("Warning: skipping file parameter '%s' of application '%s', file parameters are not supported", p.Name, a.Name)
+	}
+
+	return nil
+}
+
+// fromApplicationSet expands the template of an ApplicationSet with the
+// elements of its list generators. Other generators need a cluster or
+// an SCM provider and are not supported.
+func fromApplicationSet(name string, node *yaml.Node) ([]App, error) {
+	spec := manifest.Lookup(node, "spec")
+	template := manifest.Lookup(spec, "template")
+	if template == nil {
+		return nil, fmt.Errorf("applicationset '%s' has no template", name)
+	}
+
+	var generators []map[string]struct {
+		Elements []map[string]any `yaml:"elements"`
+	}
+	if g := manifest.Lookup(spec, "generators"); g != nil {
+		if err := g.Decode(&generators); err != nil {
+			return nil, fmt.Errorf("failed to decode generators: %w", err)
+		}
+	}
+
+	var apps []App
+	for _, generator := range generators {
+		for kind, g := range generator {
+			if kind != "list" {
+				return nil, fmt.Errorf("applicationset '%s' uses a %s generator, only list generators are supported", name, kind)
+			}
+			for _, element := range g.Elements {
+				expanded := expandTemplate(template, element)
+				appName := manifest.IDFromNode(expanded).Name
+				if appName == "" {
+					return nil, fmt.Errorf("applicationset '%s' template has no metadata.name", name)
+				}
+				elementApps, err := fromApplication(appName, expanded)
+				if err != nil {
+					return nil, err
+				}
+				apps = append(apps, elementApps...)
+			}
+		}
+	}
+
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("applicationset '%s' generates no applications", name)
+	}
+	return apps, nil
+}
+
+// expandTemplate returns a copy of a template node with "{{key}}" and
+// "{{ .key }}" placeholders in string values replaced by element values
+func expandTemplate(node *yaml.Node, element map[string]any) *yaml.Node {
+	expanded := *node
+	if node.Kind == yaml.ScalarNode {
+		for key, value := range element {
+			v := fmt.Sprint(value)
+			for _, placeholder := range []string{"{{" + key + "}}", "{{ " + key + " }}", "{{." + key + "}}", "{{ ." + key + " }}"} {
+				expanded.Value = strings.ReplaceAll(expanded.Value, placeholder, v)
+			}
+		}
+		return &expanded
+	}
+
+	expanded.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		expanded.Content[i] = expandTemplate(child, element)
+	}
+	return &expanded
+}
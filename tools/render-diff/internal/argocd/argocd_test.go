@@ -0,0 +1,87 @@
+package argocd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadApplication(t *testing.T) {
+	apps, err := Load("testdata/apps/web.yaml")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	want := []App{{
+		Name:        "web-prod",
+		Path:        "charts/web",
+		ValueFiles:  []string{"values.yaml", "values-prod.yaml"},
+		ReleaseName: "web",
+		Namespace:   "web",
+		Values: []map[string]any{
+			{"replicas": 3},
+			{"image": map[string]any{"tag": "v2"}},
+		},
+		Set:       []string{"ingress.enabled=true"},
+		SetString: []string{"build=1234"},
+	}}
+	if !reflect.DeepEqual(apps, want) {
+		t.Errorf("Load() = %#v, want %#v", apps, want)
+	}
+}
+
+func TestLoadDirectory(t *testing.T) {
+	apps, err := Load("testdata/apps")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(apps) != 2 {
+		t.Fatalf("Load() returned %d apps, want 2", len(apps))
+	}
+
+	// Multi-source applications resolve $ref value files against the
+	// repository root, and skip chart repository sources
+	api := apps[0]
+	if api.Name != "api" || api.Path != "charts/api" || api.ReleaseName != "api" {
+		t.Errorf("Load() api = %+v", api)
+	}
+	if want := []string{"../../environments/prod/api.yaml"}; !reflect.DeepEqual(api.ValueFiles, want) {
+		t.Errorf("Load() api value files = %v, want %v", api.ValueFiles, want)
+	}
+}
+
+func TestLoadApplicationSet(t *testing.T) {
+	apps, err := Load("testdata/appset.yaml")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	var got [][]string
+	for _, app := range apps {
+		got = append(got, []string{app.Name, app.Namespace, app.ValueFiles[0]})
+	}
+	want := [][]string{
+		{"worker-stage", "worker-stage", "values-stage.yaml"},
+		{"worker-prod", "worker-prod", "values-prod.yaml"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{name: "Missing path", path: "testdata/missing.yaml"},
+		{name: "No applications", path: "argocd.go"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Load(tc.path); err == nil {
+				t.Errorf("Load(%q) expected an error", tc.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+// Package capacity computes the compute capacity workloads reserve in two
+// renders, from the resource requests and limits of their pods multiplied
+// by their replica counts, and reports how a change moves it
+package capacity
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Resources is an amount of CPU and memory
+type Resources struct {
+	// CPU is in millicores
+	CPU int64 `json:"cpuMillis"`
+	// Memory is in bytes
+	Memory int64 `json:"memoryBytes"`
+}
+
+// Usage is the resources requested and limited by a pod or a set of pods
+type Usage struct {
+	Requests Resources `json:"requests"`
+	Limits   Resources `json:"limits"`
+}
+
+// apply combines every resource of u and o with f
+func (u Usage) apply(o Usage, f func(a, b int64) int64) Usage {
+	return Usage{
+		Requests: Resources{CPU: f(u.Requests.CPU, o.Requests.CPU), Memory: f(u.Requests.Memory, o.Requests.Memory)},
+		Limits:   Resources{CPU: f(u.Limits.CPU, o.Limits.CPU), Memory: f(u.Limits.Memory, o.Limits.Memory)},
+	}
+}
+
+// Add returns the sum of u and o
+func (u Usage) Add(o Usage) Usage {
+	return u.apply(o, func(a, b int64) int64 { return a + b })
+}
+
+// Sub returns the difference of u and o
+func (u Usage) Sub(o Usage) Usage {
+	return u.apply(o, func(a, b int64) int64 { return a - b })
+}
+
+// scale multiplies every resource of u by n
+func (u Usage) scale(n int64) Usage {
+	return u.apply(Usage{}, func(a, _ int64) int64 { return a * n })
+}
+
+// Footprint is the capacity reserved by one workload
+type Footprint struct {
+	// MinReplicas and MaxReplicas differ when the workload is scaled by
+	// a HorizontalPodAutoscaler
+	MinReplicas int64 `json:"minReplicas"`
+	MaxReplicas int64 `json:"maxReplicas"`
+	// PerNode is set for DaemonSets, which run one pod on every node.
+	// Their totals are for a single node.
+	PerNode bool `json:"perNode,omitempty"`
+	// Pod is the usage of a single pod
+	Pod Usage `json:"pod"`
+}
+
+// Min returns the usage of all pods at the lowest replica count. A nil
+// footprint, for a workload that does not exist, uses nothing.
+func (f *Footprint) Min() Usage {
+	if f == nil {
+		return Usage{}
+	}
+	return f.Pod.scale(f.MinReplicas)
+}
+
+// Max returns the usage of all pods at the highest replica count
+func (f *Footprint) Max() Usage {
+	if f == nil {
+		return Usage{}
+	}
+	return f.Pod.scale(f.MaxReplicas)
+}
+
+// replicas describes the replica count of the footprint
+func (f *Footprint) replicas() string {
+	switch {
+	case f == nil:
+		return "-"
+	case f.PerNode:
+		return "per node"
+	case f.MinReplicas == f.MaxReplicas:
+		return strconv.FormatInt(f.MinReplicas, 10)
+	default:
+		return fmt.Sprintf("%d-%d", f.MinReplicas, f.MaxReplicas)
+	}
+}
+
+// Change is a workload whose footprint changed
+type Change struct {
+	manifest.ResourceID
+	// Before is nil when the workload was added
+	Before *Footprint `json:"before,omitempty"`
+	// After is nil when the workload was removed
+	After *Footprint `json:"after,omitempty"`
+}
+
+// Delta returns the change in usage at the lowest and highest replica counts
+func (c Change) Delta() (Usage, Usage) {
+	return c.After.Min().Sub(c.Before.Min()), c.After.Max().Sub(c.Before.Max())
+}
+
+// Total sums the deltas of all changes
+func Total(changes []Change) (Usage, Usage) {
+	var minDelta, maxDelta Usage
+	for _, c := range changes {
+		lo, hi := c.Delta()
+		minDelta, maxDelta = minDelta.Add(lo), maxDelta.Add(hi)
+	}
+	return minDelta, maxDelta
+}
+
+// Compare returns the workloads whose footprint differs between two
+// renders, ordered as they appear in the local render, followed by
+// removed workloads
+func Compare(target, local string) ([]Change, error) {
+	targetIDs, targetFootprints, err := footprints(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read target render: %w", err)
+	}
+	localIDs, localFootprints, err := footprints(local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local render: %w", err)
+	}
+
+	var changes []Change
+	for _, id := range localIDs {
+		before, after := targetFootprints[id.String()], localFootprints[id.String()]
+		if before == nil || *before != *after {
+			changes = append(changes, Change{ResourceID: id, Before: before, After: after})
+		}
+	}
+	for _, id := range targetIDs {
+		if _, ok := localFootprints[id.String()]; !ok {
+			changes = append(changes, Change{ResourceID: id, Before: targetFootprints[id.String()]})
+		}
+	}
+
+	return changes, nil
+}
+
+// footprints returns the workloads in a render in order, and their
+// footprints keyed by ResourceID string
+func footprints(render string) ([]manifest.ResourceID, map[string]*Footprint, error) {
+	docs, err := manifest.Parse(render)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	autoscalers, err := autoscaled(docs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ids []manifest.ResourceID
+	result := make(map[string]*Footprint)
+	for _, doc := range docs {
+		spec := manifest.PodSpec(doc)
+		if spec == nil {
+			continue
+		}
+
+		f, err := footprint(doc, spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", doc.ID, err)
+		}
+		if hpa, ok := autoscalers[scaleKey(doc.ID.Namespace, doc.ID.Kind, doc.ID.Name)]; ok {
+			f.MinReplicas, f.MaxReplicas = hpa[0], hpa[1]
+		}
+
+		ids = append(ids, doc.ID)
+		result[doc.ID.String()] = f
+	}
+
+	return ids, result, nil
+}
+
+// footprint reads the replica count and pod usage of a workload
+func footprint(doc *manifest.Document, spec *yaml.Node) (*Footprint, error) {
+	root := doc.Node
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+
+	f := &Footprint{}
+	var err error
+	switch doc.ID.Kind {
+	case "DaemonSet":
+		f.PerNode = true
+		f.MinReplicas = 1
+	case "Job":
+		f.MinReplicas, err = count(root, 1, "spec", "parallelism")
+	case "CronJob":
+		f.MinReplicas, err = count(root, 1, "spec", "jobTemplate", "spec", "parallelism")
+	default:
+		f.MinReplicas, err = count(root, 1, "spec", "replicas")
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.MaxReplicas = f.MinReplicas
+
+	f.Pod, err = podUsage(spec)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// podUsage returns the effective usage of a pod. Init containers run
+// before the other containers, so the pod reserves the larger of the
+// biggest init container and the sum of the other containers.
+func podUsage(spec *yaml.Node) (Usage, error) {
+	var containers, initContainers Usage
+	for _, list := range []struct {
+		key     string
+		combine func(a, b int64) int64
+		usage   *Usage
+	}{
+		{"containers", func(a, b int64) int64 { return a + b }, &containers},
+		{"initContainers", func(a, b int64) int64 { return max(a, b) }, &initContainers},
+	} {
+		entries := manifest.Lookup(spec, list.key)
+		if entries == nil {
+			continue
+		}
+		for _, entry := range entries.Content {
+			usage, err := containerUsage(entry)
+			if err != nil {
+				return Usage{}, err
+			}
+			*list.usage = list.usage.apply(usage, list.combine)
+		}
+	}
+
+	return containers.apply(initContainers, func(a, b int64) int64 { return max(a, b) }), nil
+}
+
+// containerUsage reads the requests and limits of a container
+func containerUsage(container *yaml.Node) (Usage, error) {
+	resources := manifest.Lookup(container, "resources")
+
+	var usage Usage
+	for _, section := range []struct {
+		key       string
+		resources *Resources
+	}{{"requests", &usage.Requests}, {"limits", &usage.Limits}} {
+		values := manifest.Lookup(resources, section.key)
+
+		if cpu := manifest.Lookup(values, "cpu"); cpu != nil {
+			q, err := resource.ParseQuantity(cpu.Value)
+			if err != nil {
+				return Usage{}, fmt.Errorf("invalid cpu %s %q: %w", section.key, cpu.Value, err)
+			}
+			section.resources.CPU = q.MilliValue()
+		}
+		if memory := manifest.Lookup(values, "memory"); memory != nil {
+			q, err := resource.ParseQuantity(memory.Value)
+			if err != nil {
+				return Usage{}, fmt.Errorf("invalid memory %s %q: %w", section.key, memory.Value, err)
+			}
+			section.resources.Memory = q.Value()
+		}
+	}
+
+	// Kubernetes defaults a missing request to the limit of the same resource
+	requests := manifest.Lookup(resources, "requests")
+	if manifest.Lookup(requests, "cpu") == nil {
+		usage.Requests.CPU = usage.Limits.CPU
+	}
+	if manifest.Lookup(requests, "memory") == nil {
+		usage.Requests.Memory = usage.Limits.Memory
+	}
+
+	return usage, nil
+}
+
+// autoscaled returns the minimum and maximum replicas set by the
+// HorizontalPodAutoscalers in a render, keyed by their scale target
+func autoscaled(docs []*manifest.Document) (map[string][2]int64, error) {
+	result := make(map[string][2]int64)
+	for _, doc := range docs {
+		if doc.ID.Group != "autoscaling" || doc.ID.Kind != "HorizontalPodAutoscaler" {
+			continue
+		}
+
+		root := doc.Node
+		if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+			root = root.Content[0]
+		}
+		spec := manifest.Lookup(root, "spec")
+		ref := manifest.Lookup(spec, "scaleTargetRef")
+
+		minReplicas, err := count(spec, 1, "minReplicas")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", doc.ID, err)
+		}
+		maxReplicas, err := count(spec, minReplicas, "maxReplicas")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", doc.ID, err)
+		}
+
+		kind, name := manifest.Lookup(ref, "kind"), manifest.Lookup(ref, "name")
+		if kind == nil || name == nil {
+			continue
+		}
+		result[scaleKey(doc.ID.Namespace, kind.Value, name.Value)] = [2]int64{minReplicas, maxReplicas}
+	}
+	return result, nil
+}
+
+// scaleKey identifies the target of an autoscaler
+func scaleKey(namespace, kind, name string) string {
+	return namespace + "/" + kind + "/" + name
+}
+
+// count reads an integer field below node, or returns def when it is unset
+func count(node *yaml.Node, def int64, path ...string) (int64, error) {
+	for _, key := range path {
+		node = manifest.Lookup(node, key)
+	}
+	if node == nil {
+		return def, nil
+	}
+
+	n, err := strconv.ParseInt(node.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", strings.Join(path, "."), node.Value)
+	}
+	return n, nil
+}
+
+// Summary describes the total change in requested capacity, e.g.
+// "CPU requests +6 vCPU, memory requests +12Gi"
+func Summary(changes []Change) string {
+	lo, hi := Total(changes)
+	return fmt.Sprintf("CPU requests %s, memory requests %s",
+		formatRange(lo.Requests.CPU, hi.Requests.CPU, formatCPU),
+		formatRange(lo.Requests.Memory, hi.Requests.Memory, formatMemory))
+}
+
+// Header names the columns of Rows
+var Header = []string{"WORKLOAD", "REPLICAS", "CPU REQUESTS", "CPU LIMITS", "MEMORY REQUESTS", "MEMORY LIMITS"}
+
+// Rows formats each change, followed by the total, as table cells. Deltas
+// of autoscaled workloads are written as ranges from the lowest to the
+// highest replica count.
+func Rows(changes []Change) [][]string {
+	row := func(name, replicas string, lo, hi Usage) []string {
+		return []string{
+			name,
+			replicas,
+			formatRange(lo.Requests.CPU, hi.Requests.CPU, formatCPU),
+			formatRange(lo.Limits.CPU, hi.Limits.CPU, formatCPU),
+			formatRange(lo.Requests.Memory, hi.Requests.Memory, formatMemory),
+			formatRange(lo.Limits.Memory, hi.Limits.Memory, formatMemory),
+		}
+	}
+
+	rows := make([][]string, 0, len(changes)+1)
+	for _, c := range changes {
+		replicas := c.After.replicas()
+		if before := c.Before.replicas(); before != replicas {
+			replicas = before + " -> " + replicas
+		}
+		lo, hi := c.Delta()
+		rows = append(rows, row(c.ResourceID.String(), replicas, lo, hi))
+	}
+	lo, hi := Total(changes)
+	return append(rows, row("Total", "", lo, hi))
+}
+
+// formatRange formats the deltas at the lowest and highest replica counts
+func formatRange(lo, hi int64, format func(int64) string) string {
+	if lo == hi {
+		return signed(lo, format)
+	}
+	return signed(lo, format) + " to " + signed(hi, format)
+}
+
+// signed formats a delta with an explicit plus sign
+func signed(n int64, format func(int64) string) string {
+	switch {
+	case n > 0:
+		return "+" + format(n)
+	case n < 0:
+		return format(n)
+	default:
+		return "0"
+	}
+}
+
+// formatCPU formats millicores as vCPU
+func formatCPU(millis int64) string {
+	return strconv.FormatFloat(float64(millis)/1000, 'f', -1, 64) + " vCPU"
+}
+
+// formatMemory formats bytes with the largest binary unit that keeps the
+// value at one or more, rounded to two decimals
+func formatMemory(bytes int64) string {
+	abs := bytes
+	if abs < 0 {
+		abs = -abs
+	}
+
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"Ti", 1 << 40}, {"Gi", 1 << 30}, {"Mi", 1 << 20}, {"Ki", 1 << 10}} {
+		if abs >= unit.size {
+			value := strconv.FormatFloat(float64(bytes)/float64(unit.size), 'f', 2, 64)
+			value = strings.TrimSuffix(strings.TrimRight(value, "0"), ".")
+			return value + unit.suffix
+		}
+	}
+	return strconv.FormatInt(bytes, 10)
+}
+
+// Write writes the capacity changes as a table to w. Nothing is written
+// when there are none.
+func Write(w io.Writer, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "\nCapacity: %s\n", Summary(changes)); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cells := range append([][]string{Header}, Rows(changes)...) {
+		_, _ = fmt.Fprintf(tw, "  %s\n", strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,197 @@
+package capacity
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  replicas: 2
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          resources:
+            requests:
+              cpu: "2"
+      containers:
+        - name: app
+          resources:
+            requests:
+              cpu: 500m
+              memory: 256Mi
+            limits:
+              cpu: "1"
+              memory: 512Mi
+        - name: proxy
+          resources:
+            requests:
+              cpu: 250m
+              memory: 128Mi
+`
+
+const hpa = `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+  namespace: prod
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+  minReplicas: 2
+  maxReplicas: 10
+`
+
+const daemonSet = `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      containers:
+        - name: agent
+          resources:
+            requests:
+              cpu: 100m
+              memory: 64Mi
+`
+
+const limitsOnly = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: worker
+          resources:
+            limits:
+              cpu: "1"
+              memory: 512Mi
+`
+
+func TestCompare(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		local  string
+		want   []string
+	}{
+		{
+			name:   "No changes",
+			target: deployment + "---\n" + daemonSet,
+			local:  deployment + "---\n" + daemonSet,
+		},
+		{
+			name:   "Scaled up",
+			target: deployment,
+			local:  strings.Replace(deployment, "replicas: 2", "replicas: 6", 1),
+			want: []string{
+				"apps/v1/Deployment/prod/web 2 -> 6 +8 vCPU +4 vCPU +1.5Gi +2Gi",
+				"Total  +8 vCPU +4 vCPU +1.5Gi +2Gi",
+			},
+		},
+		{
+			name:   "Autoscaler added",
+			target: deployment,
+			local:  deployment + "---\n" + hpa,
+			want: []string{
+				"apps/v1/Deployment/prod/web 2 -> 2-10 0 to +16 vCPU 0 to +8 vCPU 0 to +3Gi 0 to +4Gi",
+				"Total  0 to +16 vCPU 0 to +8 vCPU 0 to +3Gi 0 to +4Gi",
+			},
+		},
+		{
+			name:   "Requests lowered",
+			target: deployment,
+			local:  strings.Replace(deployment, "memory: 256Mi", "memory: 128Mi", 1),
+			want: []string{
+				"apps/v1/Deployment/prod/web 2 0 0 -256Mi 0",
+				"Total  0 0 -256Mi 0",
+			},
+		},
+		{
+			name:  "Requests default to limits",
+			local: limitsOnly,
+			want: []string{
+				"apps/v1/Deployment/worker - -> 2 +2 vCPU +2 vCPU +1Gi +1Gi",
+				"Total  +2 vCPU +2 vCPU +1Gi +1Gi",
+			},
+		},
+		{
+			name:   "Added and removed workloads",
+			target: daemonSet,
+			local:  strings.Replace(deployment, `cpu: "2"`, "cpu: 100m", 1),
+			want: []string{
+				"apps/v1/Deployment/prod/web - -> 2 +1.5 vCPU +2 vCPU +768Mi +1Gi",
+				"apps/v1/DaemonSet/agent per node -> - -0.1 vCPU 0 -64Mi 0",
+				"Total  +1.4 vCPU +2 vCPU +704Mi +1Gi",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := Compare(tc.target, tc.local)
+			if err != nil {
+				t.Fatalf("Compare() failed: %v", err)
+			}
+			if len(changes) == 0 {
+				if tc.want != nil {
+					t.Errorf("Compare() found no changes, want %q", tc.want)
+				}
+				return
+			}
+
+			var got []string
+			for _, row := range Rows(changes) {
+				got = append(got, strings.Join(row, " "))
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Rows() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+
+	t.Run("Invalid quantity", func(t *testing.T) {
+		_, err := Compare("", strings.Replace(deployment, "memory: 512Mi", "memory: lots", 1))
+		if err == nil || !strings.Contains(err.Error(), `invalid memory limits "lots"`) {
+			t.Errorf("Compare() error = %v, want an invalid memory error", err)
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, nil); err != nil || b.Len() != 0 {
+		t.Errorf("Write() with no changes wrote %q, %v", b.String(), err)
+	}
+
+	changes, err := Compare(deployment, strings.Replace(deployment, "replicas: 2", "replicas: 6", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&b, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+Capacity: CPU requests +8 vCPU, memory requests +1.5Gi
+  WORKLOAD                     REPLICAS  CPU REQUESTS  CPU LIMITS  MEMORY REQUESTS  MEMORY LIMITS
+  apps/v1/Deployment/prod/web  2 -> 6    +8 vCPU       +4 vCPU     +1.5Gi           +2Gi
+  Total                                  +8 vCPU       +4 vCPU     +1.5Gi           +2Gi
+`
+	if b.String() != want {
+		t.Errorf("Write() =\n%s\nwant:\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,136 @@
+// Package config reads the .render-diff.yaml project file, which declares
+// named render targets shared by developers and CI
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/ignore"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the project file in the repository root
+const FileName = ".render-diff.yaml"
+
+// Config is the layout of the project file
+type Config struct {
+	Targets []Target `yaml:"targets"`
+}
+
+// Target is a chart or kustomization rendered with a fixed set of settings
+type Target struct {
+	Name string `yaml:"name"`
+	// Path is relative to the repository root
+	Path string `yaml:"path"`
+	// Values are looked up like --values, starting from Path
+	Values      []string `yaml:"values"`
+	ReleaseName string   `yaml:"releaseName"`
+	Namespace   string   `yaml:"namespace"`
+	// Ignore holds rules in the --ignore format, e.g. "Deployment:/spec/replicas"
+	Ignore []string `yaml:"ignore"`
+	Output string   `yaml:"output"`
+}
+
+// Load reads the project file in repoRoot
+func Load(repoRoot string) (*Config, error) {
+	filename := filepath.Join(repoRoot, FileName)
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project config: %w", err)
+	}
+
+	var c Config
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filename, err)
+	}
+	return &c, nil
+}
+
+// validate checks that every target has a unique name and a path
+// inside the repository, and that its ignore rules parse
+func (c *Config) validate() error {
+	if len(c.Targets) == 0 {
+		return fmt.Errorf("no targets defined")
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range c.Targets {
+		if t.Name == "" {
+			return fmt.Errorf("target %d has no name", i+1)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("target '%s' is defined more than once", t.Name)
+		}
+		seen[t.Name] = true
+
+		if t.Path == "" {
+			return fmt.Errorf("target '%s' has no path", t.Name)
+		}
+		p := path.Clean(filepath.ToSlash(t.Path))
+		if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+			return fmt.Errorf("target '%s' path '%s' must be relative to the repository root", t.Name, t.Path)
+		}
+		c.Targets[i].Path = p
+
+		if _, err := t.IgnoreRules(); err != nil {
+			return fmt.Errorf("target '%s': %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// Select returns the targets with the given names, in the order given
+func (c *Config) Select(names []string) ([]Target, error) {
+	var targets []Target
+	for _, name := range names {
+		i := c.index(name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown target '%s', %s defines: %s", name, FileName, strings.Join(c.names(), ", "))
+		}
+		targets = append(targets, c.Targets[i])
+	}
+	return targets, nil
+}
+
+// index returns the position of the named target, or -1
+func (c *Config) index(name string) int {
+	for i, t := range c.Targets {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// names lists the target names in file order
+func (c *Config) names() []string {
+	names := make([]string, len(c.Targets))
+	for i, t := range c.Targets {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// IgnoreRules parses the target's ignore rules
+func (t Target) IgnoreRules() ([]ignore.Rule, error) {
+	rules := make([]ignore.Rule, 0, len(t.Ignore))
+	for _, spec := range t.Ignore {
+		rule, err := ignore.ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const validConfig = `
+targets:
+  - name: web-prod
+    path: charts/web/
+    values:
+      - values-prod.yaml
+      - ../../common/values-prod.yaml
+    releaseName: web
+    namespace: web-prod
+    ignore:
+      - "Deployment:/metadata/annotations/checksum~1config"
+    output: markdown
+  - name: api-dev
+    path: kustomize/api/overlays/dev
+`
+
+// writeConfig writes a project file into a new repository root
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load(writeConfig(t, validConfig))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	want := []Target{
+		{
+			Name:        "web-prod",
+			Path:        "charts/web",
+			Values:      []string{"values-prod.yaml", "../../common/values-prod.yaml"},
+			ReleaseName: "web",
+			Namespace:   "web-prod",
+			Ignore:      []string{"Deployment:/metadata/annotations/checksum~1config"},
+			Output:      "markdown",
+		},
+		{Name: "api-dev", Path: "kustomize/api/overlays/dev"},
+	}
+	if !reflect.DeepEqual(c.Targets, want) {
+		t.Errorf("Load() targets = %+v, want %+v", c.Targets, want)
+	}
+
+	rules, err := c.Targets[0].IgnoreRules()
+	if err != nil || len(rules) != 1 || rules[0].Kind != "Deployment" {
+		t.Errorf("IgnoreRules() = %+v, %v", rules, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "no targets",
+			content: "targets: []\n",
+			wantErr: "no targets defined",
+		},
+		{
+			name:    "duplicate names",
+			content: "targets:\n  - {name: web, path: charts/web}\n  - {name: web, path: charts/web-v2}\n",
+			wantErr: "defined more than once",
+		},
+		{
+			name:    "missing path",
+			content: "targets:\n  - name: web\n",
+			wantErr: "has no path",
+		},
+		{
+			name:    "path outside the repository",
+			content: "targets:\n  - {name: web, path: ../other/charts/web}\n",
+			wantErr: "must be relative to the repository root",
+		},
+		{
+			name:    "invalid ignore rule",
+			content: "targets:\n  - {name: web, path: charts/web, ignore: [\"/spec/[\"]}\n",
+			wantErr: "invalid ignore rule",
+		},
+		{
+			name:    "unknown field",
+			content: "targets:\n  - {name: web, path: charts/web, valuesFiles: [values-prod.yaml]}\n",
+			wantErr: "valuesFiles",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Load() error = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	if _, err := Load(t.TempDir()); err == nil {
+		t.Error("Load() expected an error for a missing project file")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	c, err := Load(writeConfig(t, validConfig))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	targets, err := c.Select([]string{"api-dev", "web-prod"})
+	if err != nil {
+		t.Fatalf("Select() failed: %v", err)
+	}
+	if len(targets) != 2 || targets[0].Name != "api-dev" || targets[1].Name != "web-prod" {
+		t.Errorf("Select() = %+v, want api-dev and web-prod", targets)
+	}
+
+	if _, err := c.Select([]string{"web-stage"}); err == nil || !strings.Contains(err.Error(), "web-prod, api-dev") {
+		t.Errorf("Select() error = %v, want the list of defined targets", err)
+	}
+}
@@ -0,0 +1,191 @@
+// Package deprecation flags objects whose apiVersion is deprecated or
+// removed in a Kubernetes version, using a built in table of the upstream
+// deprecations, so cluster upgrades can be checked without a cluster
+package deprecation
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+)
+
+// Status is how far an API version is along its deprecation
+type Status string
+
+const (
+	// StatusDeprecated API versions are still served but will be removed
+	StatusDeprecated Status = "deprecated"
+	// StatusRemoved API versions are no longer served, applying them fails
+	StatusRemoved Status = "removed"
+)
+
+// api describes the deprecation of one kind in one API version. Versions
+// are Kubernetes minor versions, e.g. "1.25".
+type api struct {
+	deprecated  string
+	removed     string
+	replacement string
+}
+
+// apis maps apiVersion/kind to its deprecation, following
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var apis = map[string]api{
+	"extensions/v1beta1/Deployment":        {"1.9", "1.16", "apps/v1"},
+	"extensions/v1beta1/DaemonSet":         {"1.9", "1.16", "apps/v1"},
+	"extensions/v1beta1/ReplicaSet":        {"1.9", "1.16", "apps/v1"},
+	"extensions/v1beta1/NetworkPolicy":     {"1.9", "1.16", "networking.k8s.io/v1"},
+	"extensions/v1beta1/PodSecurityPolicy": {"1.10", "1.16", "policy/v1beta1"},
+	"apps/v1beta1/Deployment":              {"1.9", "1.16", "apps/v1"},
+	"apps/v1beta1/StatefulSet":             {"1.9", "1.16", "apps/v1"},
+	"apps/v1beta2/Deployment":              {"1.9", "1.16", "apps/v1"},
+	"apps/v1beta2/StatefulSet":             {"1.9", "1.16", "apps/v1"},
+	"apps/v1beta2/DaemonSet":               {"1.9", "1.16", "apps/v1"},
+	"apps/v1beta2/ReplicaSet":              {"1.9", "1.16", "apps/v1"},
+
+	"extensions/v1beta1/Ingress":                                          {"1.14", "1.22", "networking.k8s.io/v1"},
+	"networking.k8s.io/v1beta1/Ingress":                                   {"1.19", "1.22", "networking.k8s.io/v1"},
+	"networking.k8s.io/v1beta1/IngressClass":                              {"1.19", "1.22", "networking.k8s.io/v1"},
+	"apiextensions.k8s.io/v1beta1/CustomResourceDefinition":               {"1.16", "1.22", "apiextensions.k8s.io/v1"},
+	"admissionregistration.k8s.io/v1beta1/MutatingWebhookConfiguration":   {"1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	"admissionregistration.k8s.io/v1beta1/ValidatingWebhookConfiguration": {"1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	"apiregistration.k8s.io/v1beta1/APIService":                           {"1.19", "1.22", "apiregistration.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1/Role":                              {"1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1/ClusterRole":                       {"1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1/RoleBinding":                       {"1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1/ClusterRoleBinding":                {"1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	"scheduling.k8s.io/v1beta1/PriorityClass":                             {"1.14", "1.22", "scheduling.k8s.io/v1"},
+	"storage.k8s.io/v1beta1/StorageClass":                                 {"1.19", "1.22", "storage.k8s.io/v1"},
+	"storage.k8s.io/v1beta1/CSIDriver":                                    {"1.19", "1.22", "storage.k8s.io/v1"},
+	"storage.k8s.io/v1beta1/CSINode":                                      {"1.17", "1.22", "storage.k8s.io/v1"},
+	"storage.k8s.io/v1beta1/VolumeAttachment":                             {"1.19", "1.22", "storage.k8s.io/v1"},
+	"coordination.k8s.io/v1beta1/Lease":                                   {"1.19", "1.22", "coordination.k8s.io/v1"},
+	"certificates.k8s.io/v1beta1/CertificateSigningRequest":               {"1.19", "1.22", "certificates.k8s.io/v1"},
+
+	"batch/v1beta1/CronJob":                       {"1.21", "1.25", "batch/v1"},
+	"discovery.k8s.io/v1beta1/EndpointSlice":      {"1.21", "1.25", "discovery.k8s.io/v1"},
+	"events.k8s.io/v1beta1/Event":                 {"1.19", "1.25", "events.k8s.io/v1"},
+	"autoscaling/v2beta1/HorizontalPodAutoscaler": {"1.22", "1.25", "autoscaling/v2"},
+	"policy/v1beta1/PodDisruptionBudget":          {"1.21", "1.25", "policy/v1"},
+	"policy/v1beta1/PodSecurityPolicy":            {"1.21", "1.25", ""},
+	"node.k8s.io/v1beta1/RuntimeClass":            {"1.20", "1.25", "node.k8s.io/v1"},
+
+	"autoscaling/v2beta2/HorizontalPodAutoscaler":                     {"1.23", "1.26", "autoscaling/v2"},
+	"flowcontrol.apiserver.k8s.io/v1beta1/FlowSchema":                 {"1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	"flowcontrol.apiserver.k8s.io/v1beta1/PriorityLevelConfiguration": {"1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	"storage.k8s.io/v1beta1/CSIStorageCapacity":                       {"1.24", "1.27", "storage.k8s.io/v1"},
+	"flowcontrol.apiserver.k8s.io/v1beta2/FlowSchema":                 {"1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	"flowcontrol.apiserver.k8s.io/v1beta2/PriorityLevelConfiguration": {"1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	"flowcontrol.apiserver.k8s.io/v1beta3/FlowSchema":                 {"1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	"flowcontrol.apiserver.k8s.io/v1beta3/PriorityLevelConfiguration": {"1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// Finding is an object of the local render that uses a deprecated or
+// removed API version
+type Finding struct {
+	manifest.ResourceID
+	Status Status `json:"status"`
+	// Since is the Kubernetes version that deprecated or removed the API version
+	Since string `json:"since"`
+	// Replacement is the apiVersion to migrate to, if there is one
+	Replacement string `json:"replacement,omitempty"`
+	// Introduced is set when the target render does not have the object in
+	// this API version, so the change introduces it
+	Introduced bool `json:"introduced"`
+}
+
+// Description explains the finding without the object
+func (f Finding) Description() string {
+	desc := fmt.Sprintf("%s in Kubernetes %s", f.Status, f.Since)
+	if f.Replacement != "" {
+		desc += ", use " + f.Replacement
+	}
+	if f.Introduced {
+		desc += " (introduced by this change)"
+	}
+	return desc
+}
+
+// String describes the finding on a single line
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.ResourceID, f.Description())
+}
+
+// Check returns the objects of the local render whose API version is
+// deprecated or removed in kubeVersion, in the order they appear
+func Check(target, local, kubeVersion string) ([]Finding, error) {
+	version, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kube version %q: %w", kubeVersion, err)
+	}
+	targetDocs, err := manifest.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target render: %w", err)
+	}
+	localDocs, err := manifest.Parse(local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse local render: %w", err)
+	}
+
+	targetIndex := manifest.Index(targetDocs)
+
+	var findings []Finding
+	for _, doc := range localDocs {
+		a, ok := apis[doc.ID.APIVersion()+"/"+doc.ID.Kind]
+		if !ok {
+			continue
+		}
+
+		f := Finding{ResourceID: doc.ID, Replacement: a.replacement}
+		switch {
+		case reached(version, a.removed):
+			f.Status, f.Since = StatusRemoved, a.removed
+		case reached(version, a.deprecated):
+			f.Status, f.Since = StatusDeprecated, a.deprecated
+		default:
+			continue
+		}
+		if _, ok := targetIndex[doc.ID.String()]; !ok {
+			f.Introduced = true
+		}
+		findings = append(findings, f)
+	}
+
+	return findings, nil
+}
+
+// reached reports whether version is at or past the minor version since.
+// Patch versions and suffixes like "-gke.100" are ignored.
+func reached(version *semver.Version, since string) bool {
+	s := semver.MustParse(since)
+	if version.Major() != s.Major() {
+		return version.Major() > s.Major()
+	}
+	return version.Minor() >= s.Minor()
+}
+
+// Write writes a human readable list of findings to w, naming the
+// kubeVersion they were checked against. Nothing is written when there
+// are none.
+func Write(w io.Writer, kubeVersion string, findings []Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	objectStr := "object uses"
+	if len(findings) != 1 {
+		objectStr = "objects use"
+	}
+	if _, err := fmt.Fprintf(w, "\nAPI versions: %d %s an API version deprecated or removed in Kubernetes %s:\n", len(findings), objectStr, kubeVersion); err != nil {
+		return err
+	}
+
+	for _, f := range findings {
+		if _, err := fmt.Fprintf(w, "  - [%s] %s\n", f.Status, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
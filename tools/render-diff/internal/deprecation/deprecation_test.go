@@ -0,0 +1,143 @@
+package deprecation
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const cronJob = `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: cleanup
+`
+
+const hpa = `apiVersion: autoscaling/v2beta2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+  namespace: prod
+`
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		target      string
+		local       string
+		kubeVersion string
+		want        []string
+	}{
+		{
+			name:        "Current API versions",
+			target:      deployment,
+			local:       deployment,
+			kubeVersion: "1.30",
+		},
+		{
+			name:        "Deprecated but still served",
+			target:      cronJob,
+			local:       cronJob,
+			kubeVersion: "v1.23.4",
+			want:        []string{"deprecated batch/v1beta1/CronJob/cleanup: deprecated in Kubernetes 1.21, use batch/v1"},
+		},
+		{
+			name:        "Removed",
+			target:      cronJob + "---\n" + hpa,
+			local:       cronJob + "---\n" + hpa,
+			kubeVersion: "v1.30.5-gke.1014001",
+			want: []string{
+				"removed batch/v1beta1/CronJob/cleanup: removed in Kubernetes 1.25, use batch/v1",
+				"removed autoscaling/v2beta2/HorizontalPodAutoscaler/prod/web: removed in Kubernetes 1.26, use autoscaling/v2",
+			},
+		},
+		{
+			name:        "Introduced by the change",
+			target:      deployment,
+			local:       deployment + "---\n" + hpa,
+			kubeVersion: "1.25",
+			want:        []string{"deprecated autoscaling/v2beta2/HorizontalPodAutoscaler/prod/web: deprecated in Kubernetes 1.23, use autoscaling/v2 (introduced by this change)"},
+		},
+		{
+			name:        "Older than the deprecation",
+			target:      "",
+			local:       hpa,
+			kubeVersion: "1.22",
+		},
+		{
+			name:        "Only in the target render",
+			target:      cronJob,
+			local:       deployment,
+			kubeVersion: "1.30",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings, err := Check(tc.target, tc.local, tc.kubeVersion)
+			if err != nil {
+				t.Fatalf("Check() failed: %v", err)
+			}
+
+			var got []string
+			for _, f := range findings {
+				got = append(got, string(f.Status)+" "+f.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Check() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+
+	t.Run("Invalid kube version", func(t *testing.T) {
+		if _, err := Check("", cronJob, "latest"); err == nil {
+			t.Error("Check() expected an error for an invalid kube version")
+		}
+	})
+}
+
+func TestTable(t *testing.T) {
+	for key, a := range apis {
+		if strings.Count(key, "/") < 1 {
+			t.Errorf("%s: key is not apiVersion/kind", key)
+		}
+		removed, err := semver.NewVersion(a.removed)
+		if err != nil {
+			t.Errorf("%s: invalid removed version: %v", key, err)
+			continue
+		}
+		if !reached(removed, a.deprecated) {
+			t.Errorf("%s: removed in %s before it was deprecated in %s", key, a.removed, a.deprecated)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, "1.30", nil); err != nil || b.Len() != 0 {
+		t.Errorf("Write() with no findings wrote %q, %v", b.String(), err)
+	}
+
+	findings, err := Check("", cronJob, "1.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&b, "1.30", findings); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+API versions: 1 object uses an API version deprecated or removed in Kubernetes 1.30:
+  - [removed] batch/v1beta1/CronJob/cleanup: removed in Kubernetes 1.25, use batch/v1 (introduced by this change)
+`
+	if b.String() != want {
+		t.Errorf("Write() =\n%s\nwant:\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,246 @@
+// Package destructive finds changes between two renders that cannot be
+// applied in place, or that delete data, using a built in catalogue of
+// immutable fields and dangerous removals
+package destructive
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Risk describes what happens when a destructive change is applied
+type Risk string
+
+const (
+	// RiskReplacement means the object must be deleted and recreated,
+	// as the API server rejects the change
+	RiskReplacement Risk = "replacement"
+	// RiskDataLoss means applying the change deletes stored data
+	RiskDataLoss Risk = "data-loss"
+)
+
+// Label describes the risk for people reading a report
+func (r Risk) Label() string {
+	if r == RiskDataLoss {
+		return "data loss risk"
+	}
+	return "requires replacement"
+}
+
+// Change is a destructive change to one object
+type Change struct {
+	manifest.ResourceID
+	Risk Risk `json:"risk"`
+	// Field is the changed field as a go-patch path. It is empty when
+	// the whole object was removed.
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// String describes the change on a single line
+func (c Change) String() string {
+	if c.Field == "" {
+		return fmt.Sprintf("%s: %s", c.ResourceID, c.Reason)
+	}
+	return fmt.Sprintf("%s %s: %s", c.ResourceID, c.Field, c.Reason)
+}
+
+// kind identifies a kind by API group, ignoring the version
+type kind struct {
+	group string
+	kind  string
+}
+
+// field is an immutable field of a kind
+type field struct {
+	path   string
+	reason string
+}
+
+// immutableFields lists fields that cannot be changed once an object
+// exists. Changing them fails on apply unless the object is replaced.
+var immutableFields = map[kind][]field{
+	{"apps", "Deployment"}: {
+		{"/spec/selector", "the selector is immutable"},
+	},
+	{"apps", "ReplicaSet"}: {
+		{"/spec/selector", "the selector is immutable"},
+	},
+	{"apps", "DaemonSet"}: {
+		{"/spec/selector", "the selector is immutable"},
+	},
+	{"apps", "StatefulSet"}: {
+		{"/spec/selector", "the selector is immutable"},
+		{"/spec/serviceName", "the service name is immutable"},
+		{"/spec/podManagementPolicy", "the pod management policy is immutable"},
+		{"/spec/volumeClaimTemplates", "volume claim templates are immutable, replacing the StatefulSet does not resize or migrate existing volumes"},
+	},
+	{"batch", "Job"}: {
+		{"/spec/selector", "the selector is immutable"},
+		{"/spec/template", "the pod template of a Job is immutable"},
+	},
+	{"", "Service"}: {
+		{"/spec/clusterIP", "the cluster IP is immutable"},
+		{"/spec/clusterIPs", "the cluster IPs are immutable"},
+		{"/spec/type", "changing the type can release the external IP address and load balancer"},
+	},
+	{"", "PersistentVolumeClaim"}: {
+		{"/spec/storageClassName", "the storage class is immutable, replacing the claim provisions a new empty volume"},
+		{"/spec/accessModes", "access modes are immutable, replacing the claim provisions a new empty volume"},
+		{"/spec/volumeName", "the bound volume is immutable"},
+		{"/spec/selector", "the selector is immutable"},
+	},
+	{"rbac.authorization.k8s.io", "RoleBinding"}: {
+		{"/roleRef", "the role reference is immutable"},
+	},
+	{"rbac.authorization.k8s.io", "ClusterRoleBinding"}: {
+		{"/roleRef", "the role reference is immutable"},
+	},
+	{"storage.k8s.io", "StorageClass"}: {
+		{"/provisioner", "the provisioner is immutable"},
+		{"/parameters", "parameters are immutable"},
+		{"/reclaimPolicy", "the reclaim policy is immutable"},
+		{"/volumeBindingMode", "the volume binding mode is immutable"},
+	},
+}
+
+// defaults holds the values the API server sets for unset immutable
+// fields, so that explicitly setting a default is not reported
+var defaults = map[kind]map[string]any{
+	{"", "Service"}: {"/spec/type": "ClusterIP"},
+}
+
+// dangerousRemovals lists kinds whose removal deletes data
+var dangerousRemovals = map[kind]string{
+	{"", "PersistentVolumeClaim"}:                        "removing the claim can delete its volume and data",
+	{"", "PersistentVolume"}:                             "removing the volume can delete its data, depending on the reclaim policy",
+	{"", "Namespace"}:                                    "removing the namespace deletes every object in it",
+	{"apiextensions.k8s.io", "CustomResourceDefinition"}: "removing the definition deletes every custom resource of its kind",
+}
+
+// Detect compares two renders and returns the destructive changes, ordered
+// as the objects appear in the target render
+func Detect(target, local string) ([]Change, error) {
+	targetDocs, err := manifest.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target render: %w", err)
+	}
+	localDocs, err := manifest.Parse(local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse local render: %w", err)
+	}
+
+	// Objects are matched without their version, so moving an object to
+	// a new API version is not reported as removing it
+	localIndex := make(map[manifest.ResourceID]*manifest.Document, len(localDocs))
+	for _, doc := range localDocs {
+		localIndex[identity(doc.ID)] = doc
+	}
+
+	var changes []Change
+	for _, doc := range targetDocs {
+		k := kind{doc.ID.Group, doc.ID.Kind}
+
+		other, ok := localIndex[identity(doc.ID)]
+		if !ok {
+			if reason, dangerous := dangerousRemovals[k]; dangerous {
+				changes = append(changes, Change{ResourceID: doc.ID, Risk: RiskDataLoss, Reason: reason})
+			}
+			continue
+		}
+
+		for _, f := range immutableFields[k] {
+			changed, err := fieldChanged(doc.Node, other.Node, f.path, defaults[k][f.path])
+			if err != nil {
+				return nil, err
+			}
+			if changed {
+				changes = append(changes, Change{ResourceID: doc.ID, Risk: risk(k), Field: f.path, Reason: f.reason})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// identity returns the group, kind, namespace and name of an object,
+// which stay the same across API versions
+func identity(id manifest.ResourceID) manifest.ResourceID {
+	id.Version = ""
+	return id
+}
+
+// risk returns the risk of replacing an object of kind k. Replacing a
+// claim deletes it, and with it the data on its volume.
+func risk(k kind) Risk {
+	if _, ok := dangerousRemovals[k]; ok {
+		return RiskDataLoss
+	}
+	return RiskReplacement
+}
+
+// fieldChanged reports whether the value at path differs between two
+// versions of an object, including the field being added or removed.
+// A missing field is compared as unset, unless unset is nil.
+func fieldChanged(target, local *yaml.Node, path string, unset any) (bool, error) {
+	p, err := manifest.ParsePath(path)
+	if err != nil {
+		return false, err
+	}
+
+	a, err := decode(p.Find(target), path, unset)
+	if err != nil {
+		return false, err
+	}
+	b, err := decode(p.Find(local), path, unset)
+	if err != nil {
+		return false, err
+	}
+	return !reflect.DeepEqual(a, b), nil
+}
+
+// decode returns the decoded values of the matched nodes by path, or
+// the unset value when nothing matched
+func decode(matches []manifest.Match, path string, unset any) (map[string]any, error) {
+	if len(matches) == 0 && unset != nil {
+		return map[string]any{path: unset}, nil
+	}
+
+	values := make(map[string]any, len(matches))
+	for _, m := range matches {
+		var v any
+		if err := m.Node.Decode(&v); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", m.Path, err)
+		}
+		values[m.Path] = v
+	}
+	return values, nil
+}
+
+// Write writes a human readable list of destructive changes to w.
+// Nothing is written when there are none.
+func Write(w io.Writer, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	changeStr := "change"
+	if len(changes) != 1 {
+		changeStr = "changes"
+	}
+	if _, err := fmt.Fprintf(w, "\n!!! %d destructive %s, requiring replacement or risking data loss:\n", len(changes), changeStr); err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		if _, err := fmt.Fprintf(w, "  - [%s] %s\n", change.Risk.Label(), change); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package destructive
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+`
+
+const pvc = `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  storageClassName: standard
+  accessModes: [ReadWriteOnce]
+`
+
+const crd = `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+`
+
+const service = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		local  string
+		want   []string
+	}{
+		{
+			name:   "No changes",
+			target: deployment + "---\n" + pvc,
+			local:  deployment + "---\n" + pvc,
+		},
+		{
+			name:   "Mutable field",
+			target: deployment,
+			local:  strings.Replace(deployment, "replicas: 2", "replicas: 3", 1),
+		},
+		{
+			name:   "Immutable selector",
+			target: deployment,
+			local:  strings.Replace(deployment, "app: web", "app: web-v2", 1),
+			want:   []string{"replacement apps/v1/Deployment/web /spec/selector"},
+		},
+		{
+			name:   "Storage class of a claim",
+			target: pvc,
+			local:  strings.Replace(pvc, "standard", "premium-rwo", 1),
+			want:   []string{"data-loss v1/PersistentVolumeClaim/data /spec/storageClassName"},
+		},
+		{
+			name:   "Removed claim",
+			target: deployment + "---\n" + pvc,
+			local:  deployment,
+			want:   []string{"data-loss v1/PersistentVolumeClaim/data "},
+		},
+		{
+			name:   "API version bump",
+			target: crd,
+			local:  strings.Replace(crd, "apiextensions.k8s.io/v1beta1", "apiextensions.k8s.io/v1", 1),
+		},
+		{
+			name:   "Removed definition",
+			target: crd,
+			local:  "",
+			want:   []string{"data-loss apiextensions.k8s.io/v1beta1/CustomResourceDefinition/widgets.example.com "},
+		},
+		{
+			name:   "Removed deployment",
+			target: deployment,
+			local:  "",
+		},
+		{
+			name:   "Default service type set explicitly",
+			target: service,
+			local:  service + "  type: ClusterIP\n",
+		},
+		{
+			name:   "Service type changed",
+			target: service,
+			local:  service + "  type: LoadBalancer\n",
+			want:   []string{"replacement v1/Service/web /spec/type"},
+		},
+		{
+			name:   "Cluster IP added",
+			target: service,
+			local:  service + "  clusterIP: None\n",
+			want:   []string{"replacement v1/Service/web /spec/clusterIP"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := Detect(tc.target, tc.local)
+			if err != nil {
+				t.Fatalf("Detect() failed: %v", err)
+			}
+
+			var got []string
+			for _, c := range changes {
+				got = append(got, string(c.Risk)+" "+c.ResourceID.String()+" "+c.Field)
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Detect() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		if _, err := Detect("a: [b\n", ""); err == nil {
+			t.Error("Detect() expected an error for invalid YAML")
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, nil); err != nil || b.Len() != 0 {
+		t.Errorf("Write() with no changes wrote %q, %v", b.String(), err)
+	}
+
+	changes, err := Detect(deployment+"---\n"+pvc, strings.Replace(deployment, "app: web", "app: api", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&b, changes); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"2 destructive changes",
+		"[requires replacement] apps/v1/Deployment/web /spec/selector: the selector is immutable",
+		"[data loss risk] v1/PersistentVolumeClaim/data: removing the claim",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Write() output missing %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/gonvenience/ytbx"
+	"github.com/homeport/dyff/pkg/dyff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// ChangeType describes how an object or field differs between two renders
+type ChangeType string
+
+const (
+	ChangeAdded     ChangeType = "added"
+	ChangeRemoved   ChangeType = "removed"
+	ChangeModified  ChangeType = "modified"
+	ChangeReordered ChangeType = "reordered"
+)
+
+// ResourceChange is a single Kubernetes object that differs between renders
+type ResourceChange struct {
+	manifest.ResourceID
+	Change ChangeType    `json:"change"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is a single changed field inside a modified object.
+// Path uses the go-patch style also used by the semantic diff report.
+type FieldChange struct {
+	Path   string     `json:"path"`
+	Change ChangeType `json:"change"`
+	Before any        `json:"before"`
+	After  any        `json:"after"`
+}
+
+// Changes converts a dyff report into one ResourceChange per changed object.
+// Objects are returned in the order dyff reports them: modified objects in
+// target order, then removed objects, then added objects.
+func Changes(report dyff.Report) []ResourceChange {
+	var changes []ResourceChange
+	index := make(map[string]int)
+
+	for _, d := range report.Diffs {
+		// Document order changes have no path, and are ignored by our compare options
+		if d.Path == nil {
+			continue
+		}
+
+		id := manifest.IDFromNode(documentNode(d.Path))
+		key := id.String()
+
+		i, ok := index[key]
+		if !ok {
+			i = len(changes)
+			index[key] = i
+			changes = append(changes, ResourceChange{ResourceID: id, Fields: []FieldChange{}})
+		}
+		rc := &changes[i]
+
+		for _, detail := range d.Details {
+			switch {
+			// Whole documents are wrapped in a document node by dyff,
+			// while field level additions and removals are not
+			case detail.Kind == dyff.ADDITION && isDocument(detail.To):
+				rc.Change = mergeChange(rc.Change, ChangeAdded)
+			case detail.Kind == dyff.REMOVAL && isDocument(detail.From):
+				rc.Change = mergeChange(rc.Change, ChangeRemoved)
+			default:
+				rc.Change = ChangeModified
+				rc.Fields = append(rc.Fields, fieldChanges(d.Path, detail)...)
+			}
+		}
+	}
+
+	return changes
+}
+
+// mergeChange keeps an object as modified once any field change was seen
+func mergeChange(current, next ChangeType) ChangeType {
+	if current == ChangeModified {
+		return current
+	}
+	return next
+}
+
+// documentNode returns the root node of the document a path points into
+func documentNode(path *ytbx.Path) *yaml.Node {
+	if path.Root == nil || path.DocumentIdx >= len(path.Root.Documents) {
+		return nil
+	}
+	return path.Root.Documents[path.DocumentIdx]
+}
+
+// isDocument reports whether a detail node wraps a whole document
+func isDocument(node *yaml.Node) bool {
+	return node != nil && node.Kind == yaml.DocumentNode
+}
+
+// fieldChanges expands a dyff detail into field changes. Additions and
+// removals of map keys are reported per key so every path is a leaf path.
+func fieldChanges(path *ytbx.Path, detail dyff.Detail) []FieldChange {
+	base := path.ToGoPatchStyle()
+
+	switch detail.Kind {
+	case dyff.ADDITION:
+		return expandMapping(base, ChangeAdded, detail.To, func(fc *FieldChange, v any) { fc.After = v })
+	case dyff.REMOVAL:
+		return expandMapping(base, ChangeRemoved, detail.From, func(fc *FieldChange, v any) { fc.Before = v })
+	case dyff.ORDERCHANGE:
+		return []FieldChange{{Path: base, Change: ChangeReordered, Before: decode(detail.From), After: decode(detail.To)}}
+	default:
+		return []FieldChange{{Path: base, Change: ChangeModified, Before: decode(detail.From), After: decode(detail.To)}}
+	}
+}
+
+// expandMapping returns one field change per key of a mapping node, or a
+// single change for the whole node (e.g. added list entries)
+func expandMapping(base string, change ChangeType, node *yaml.Node, set func(*FieldChange, any)) []FieldChange {
+	if node == nil || node.Kind != yaml.MappingNode {
+		fc := FieldChange{Path: base, Change: change}
+		set(&fc, decode(node))
+		return []FieldChange{fc}
+	}
+
+	prefix := base
+	if prefix == "/" {
+		prefix = ""
+	}
+
+	fields := make([]FieldChange, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fc := FieldChange{Path: prefix + "/" + node.Content[i].Value, Change: change}
+		set(&fc, decode(node.Content[i+1]))
+		fields = append(fields, fc)
+	}
+	return fields
+}
+
+// decode converts a YAML node into plain Go values for JSON encoding
+func decode(node *yaml.Node) any {
+	if node == nil {
+		return nil
+	}
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return node.Value
+	}
+	return v
+}
+
+// CreateResourceDiffs creates a unified diff for every changed object,
+// keyed by its ResourceID string. Added and removed objects are diffed
+// against an empty document.
+func CreateResourceDiffs(targetRender, localRender string, changes []ResourceChange, fromName, toName string) (map[string]string, error) {
+	targetDocs, err := manifest.Parse(targetRender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target render: %w", err)
+	}
+	localDocs, err := manifest.Parse(localRender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse local render: %w", err)
+	}
+
+	targetIndex := manifest.Index(targetDocs)
+	localIndex := manifest.Index(localDocs)
+
+	diffs := make(map[string]string, len(changes))
+	for _, change := range changes {
+		key := change.String()
+
+		var targetRaw, localRaw string
+		if doc, ok := targetIndex[key]; ok {
+			targetRaw = doc.Raw
+		}
+		if doc, ok := localIndex[key]; ok {
+			localRaw = doc.Raw
+		}
+
+		diffs[key] = CreateDiff(targetRaw, localRaw, fmt.Sprintf("%s (%s)", fromName, key), fmt.Sprintf("%s (%s)", toName, key))
+	}
+
+	return diffs, nil
+}
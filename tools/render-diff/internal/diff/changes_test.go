@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	target := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  replicas: 1
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+data:
+  key: value
+`
+	local := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app: web
+        tier: frontend
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: ClusterIP
+`
+
+	report, err := CompareRenders(target, local, "target", "local")
+	if err != nil {
+		t.Fatalf("CompareRenders() failed: %v", err)
+	}
+
+	changes := Changes(report)
+	if len(changes) != 3 {
+		t.Fatalf("Changes() returned %d changes, want 3: %+v", len(changes), changes)
+	}
+
+	byName := make(map[string]ResourceChange)
+	for _, c := range changes {
+		byName[c.String()] = c
+	}
+
+	deployment, ok := byName["apps/v1/Deployment/prod/web"]
+	if !ok {
+		t.Fatalf("Deployment missing from changes: %+v", changes)
+	}
+	if deployment.Change != ChangeModified {
+		t.Errorf("Deployment change = %q, want %q", deployment.Change, ChangeModified)
+	}
+
+	wantFields := []FieldChange{
+		{Path: "/spec/replicas", Change: ChangeModified, Before: 1, After: 3},
+		{Path: "/spec/template/metadata/labels/tier", Change: ChangeAdded, After: "frontend"},
+	}
+	if !reflect.DeepEqual(deployment.Fields, wantFields) {
+		t.Errorf("Deployment fields = %+v, want %+v", deployment.Fields, wantFields)
+	}
+
+	if got := byName["v1/ConfigMap/old"].Change; got != ChangeRemoved {
+		t.Errorf("ConfigMap change = %q, want %q", got, ChangeRemoved)
+	}
+
+	service := byName["v1/Service/web"]
+	if service.Change != ChangeAdded {
+		t.Errorf("Service change = %q, want %q", service.Change, ChangeAdded)
+	}
+	if len(service.Fields) != 0 {
+		t.Errorf("Added Service should have no field changes, got %+v", service.Fields)
+	}
+}
+
+// A field added to an existing document must not be reported as an added document
+func TestChangesFieldAdditionOnly(t *testing.T) {
+	target := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  a: \"1\"\n"
+	local := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  a: \"1\"\n  b: \"2\"\n"
+
+	report, err := CompareRenders(target, local, "target", "local")
+	if err != nil {
+		t.Fatalf("CompareRenders() failed: %v", err)
+	}
+
+	summary := SummarizeChanges(report)
+	if len(summary.Modified) != 1 || len(summary.Added) != 0 {
+		t.Errorf("SummarizeChanges() = %+v, want a single updated object", summary)
+	}
+}
+
+func TestCreateResourceDiffs(t *testing.T) {
+	target := "---\n# Source: chart/templates/cm.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  key: old\n"
+	local := "---\n# Source: chart/templates/cm.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  key: new\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: svc\n"
+
+	report, err := CompareRenders(target, local, "target", "local")
+	if err != nil {
+		t.Fatalf("CompareRenders() failed: %v", err)
+	}
+
+	diffs, err := CreateResourceDiffs(target, local, Changes(report), "target", "local")
+	if err != nil {
+		t.Fatalf("CreateResourceDiffs() failed: %v", err)
+	}
+
+	cm := diffs["v1/ConfigMap/cfg"]
+	if !strings.Contains(cm, "-  key: old") || !strings.Contains(cm, "+  key: new") {
+		t.Errorf("ConfigMap diff missing changed lines. Got:\n%s", cm)
+	}
+	if strings.Contains(cm, "Service") {
+		t.Errorf("ConfigMap diff contains another resource. Got:\n%s", cm)
+	}
+
+	if svc := diffs["v1/Service/svc"]; !strings.Contains(svc, "+kind: Service") {
+		t.Errorf("Service diff should show the whole object as added. Got:\n%s", svc)
+	}
+}
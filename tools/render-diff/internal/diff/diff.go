@@ -30,24 +30,24 @@ const (
 )
 
 // RenderManifests will render a Helm Chart or build a Kustomization
-// and return the rendered manifests as a string
-func RenderManifests(path string, values []string, debug bool, update bool, release string) (string, error) {
+// and return the rendered manifests as a string. The render options
+// only apply to Helm charts.
+func RenderManifests(path string, opts helm.RenderOptions) (string, error) {
 	var renderedManifests string
 	var err error
-	releaseName := release
 
 	if helm.IsHelmChart(path) {
 		// Set releaseName equal to chartName if --release-name is not supplied
-		if releaseName == "" {
-			chartName, err := helm.GetChartName(path, debug)
+		if opts.ReleaseName == "" {
+			chartName, err := helm.GetChartName(path, opts.Debug)
 			if err != nil {
-				releaseName = "release"
+				opts.ReleaseName = "release"
 			} else {
-				releaseName = chartName
+				opts.ReleaseName = chartName
 			}
 		}
 
-		renderedManifests, err = helm.RenderChart(path, releaseName, values, debug, update)
+		renderedManifests, err = helm.RenderChart(path, opts)
 		if err != nil {
 			return "", fmt.Errorf("failed to render target Chart: '%s'", err)
 		}
@@ -122,14 +122,33 @@ func CreateSemanticDiff(targetRender, localRender, fromName, toName string, plai
 		bunt.SetColorSettings(bunt.ON, bunt.ON)
 	}
 
+	diff, err := CompareRenders(targetRender, localRender, fromName, toName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create our human readable report from our diffs
+	report := dyff.HumanReport{
+		Report:          diff,
+		OmitHeader:      true,
+		UseGoPatchPaths: true,
+	}
+
+	return &report, nil
+}
+
+// CompareRenders runs the dyff comparison between two rendered manifests
+// without building a human report. It is shared by the semantic diff and
+// the per-environment summaries, which need the change list in either mode.
+func CompareRenders(targetRender, localRender, fromName, toName string) (dyff.Report, error) {
 	localRenderFile, err := createInputFileFromString(localRender, toName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse local render for semantic diff: %w", err)
+		return dyff.Report{}, fmt.Errorf("failed to parse local render for semantic diff: %w", err)
 	}
 
 	targetRenderFile, err := createInputFileFromString(targetRender, fromName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse target render for semantic diff: %w", err)
+		return dyff.Report{}, fmt.Errorf("failed to parse target render for semantic diff: %w", err)
 	}
 
 	options := []dyff.CompareOption{
@@ -139,19 +158,12 @@ func CreateSemanticDiff(targetRender, localRender, fromName, toName string, plai
 		dyff.IgnoreWhitespaceChanges(true),
 	}
 
-	diff, err := dyff.CompareInputFiles(targetRenderFile, localRenderFile, options...)
+	report, err := dyff.CompareInputFiles(targetRenderFile, localRenderFile, options...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compare manifests: %w", err)
-	}
-
-	// Create our human readable report from our diffs
-	report := dyff.HumanReport{
-		Report:          diff,
-		OmitHeader:      true,
-		UseGoPatchPaths: true,
+		return dyff.Report{}, fmt.Errorf("failed to compare manifests: %w", err)
 	}
 
-	return &report, nil
+	return report, nil
 }
 
 // createInputFileFromString parses a multi-document YAML string into a dyff compatible InputFile format
@@ -176,144 +188,101 @@ func createInputFileFromString(content string, location string) (ytbx.InputFile,
 	}, nil
 }
 
-// getDocumentName extracts the name from a Diff path
-// It uses the RootDescription which contains the K8s resource identifier
-func getDocumentNameFromDiff(diff dyff.Diff) string {
-	// The Path.RootDescription() contains the K8s resource identifier
-	// Example: "apps/v1/Deployment/helloworld"
-	desc := diff.Path.RootDescription()
-
-	if desc != "" {
-		// Remove parentheses if present: "(apps/v1/Deployment/helloworld)" -> "apps/v1/Deployment/helloworld"
-		return strings.Trim(desc, "()")
-	}
+// ChangeSummary holds the identifiers of changed objects, grouped by the
+// nature of the change
+type ChangeSummary struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
 
-	return "unknown"
+// Total returns the number of changed objects
+func (s ChangeSummary) Total() int {
+	return len(s.Added) + len(s.Removed) + len(s.Modified)
 }
 
-// sortedMapValues returns the values from a map[int]string sorted by key
-func sortedMapValues(m map[int]string) []string {
-	// Get keys and sort them
-	keys := make([]int, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+// String returns a one line description of the changes,
+// e.g. "3 changes (1 updated, 2 added)"
+func (s ChangeSummary) String() string {
+	var parts []string
+	if len(s.Modified) > 0 {
+		parts = append(parts, fmt.Sprintf("%d updated", len(s.Modified)))
+	}
+	if len(s.Added) > 0 {
+		parts = append(parts, fmt.Sprintf("%d added", len(s.Added)))
+	}
+	if len(s.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d removed", len(s.Removed)))
 	}
 
-	// Simple insertion sort since we expect small numbers of documents
-	for i := 1; i < len(keys); i++ {
-		key := keys[i]
-		j := i - 1
-		for j >= 0 && keys[j] > key {
-			keys[j+1] = keys[j]
-			j--
-		}
-		keys[j+1] = key
+	if len(parts) == 0 {
+		return "no changes"
 	}
 
-	// Build result array
-	result := make([]string, len(keys))
-	for i, k := range keys {
-		result[i] = m[k]
+	changeStr := "change"
+	if s.Total() != 1 {
+		changeStr = "changes"
 	}
-	return result
+
+	return fmt.Sprintf("%d %s (%s)", s.Total(), changeStr, strings.Join(parts, ", "))
 }
 
-// PrintChangeSummary prints a concise summary of changes categorized by type
-func PrintChangeSummary(report dyff.Report) error {
-	// Track document changes by type with their identifiers
-	added := make(map[int]string)
-	removed := make(map[int]string)
-	modified := make(map[int]string)
-
-	// Categorize each document based on the nature of its diffs
-	for _, diff := range report.Diffs {
-		docIdx := diff.Path.DocumentIdx
-		docName := getDocumentNameFromDiff(diff)
-
-		// Check if this is a document-level change (root path with no/few elements)
-		isDocumentLevel := len(diff.Path.PathElements) == 0
-
-		// Categorize based on detail kinds
-		for _, detail := range diff.Details {
-			switch detail.Kind {
-			case dyff.ADDITION:
-				if isDocumentLevel || detail.From == nil {
-					// Document was added
-					added[docIdx] = docName
-				} else {
-					// Field was added to existing document
-					modified[docIdx] = docName
-				}
-			case dyff.REMOVAL:
-				if isDocumentLevel || detail.To == nil {
-					// Document was removed
-					removed[docIdx] = docName
-				} else {
-					// Field was removed from existing document
-					modified[docIdx] = docName
-				}
-			case dyff.MODIFICATION, dyff.ORDERCHANGE:
-				// Document was modified
-				modified[docIdx] = docName
-			}
+// SummarizeChanges categorizes every changed document in a dyff report
+// as added, removed or modified
+func SummarizeChanges(report dyff.Report) ChangeSummary {
+	var summary ChangeSummary
+
+	for _, change := range Changes(report) {
+		switch change.Change {
+		case ChangeAdded:
+			summary.Added = append(summary.Added, change.String())
+		case ChangeRemoved:
+			summary.Removed = append(summary.Removed, change.String())
+		default:
+			summary.Modified = append(summary.Modified, change.String())
 		}
 	}
 
-	// Remove documents from added/removed if they also appear in modified
-	// (they were modified, not wholly added/removed)
-	for docIdx := range modified {
-		delete(added, docIdx)
-		delete(removed, docIdx)
-	}
-
-	addedCount := len(added)
-	removedCount := len(removed)
-	modifiedCount := len(modified)
-	totalObjects := addedCount + removedCount + modifiedCount
+	return summary
+}
 
-	// Build summary message
-	var parts []string
-	if modifiedCount > 0 {
-		parts = append(parts, fmt.Sprintf("%d updated", modifiedCount))
-	}
-	if addedCount > 0 {
-		parts = append(parts, fmt.Sprintf("%d added", addedCount))
-	}
-	if removedCount > 0 {
-		parts = append(parts, fmt.Sprintf("%d removed", removedCount))
-	}
+// PrintChangeSummary prints a concise summary of changes categorized by type
+func PrintChangeSummary(report dyff.Report) error {
+	return WriteChangeSummary(os.Stdout, SummarizeChanges(report))
+}
 
-	if len(parts) == 0 {
+// WriteChangeSummary writes the summary line followed by the
+// detailed lists for each category to w
+func WriteChangeSummary(w io.Writer, summary ChangeSummary) error {
+	if summary.Total() == 0 {
 		return nil
 	}
 
-	changeStr := "change"
-	if totalObjects != 1 {
-		changeStr = "changes"
+	if _, err := fmt.Fprintf(w, "\nSummary: %s\n", summary); err != nil {
+		return err
 	}
 
-	fmt.Printf("\nSummary: %d %s (%s)\n",
-		totalObjects, changeStr, strings.Join(parts, ", "))
-
 	// Print detailed lists for each category
-	if len(modified) > 0 {
-		fmt.Println("\nUpdated:")
-		for _, id := range sortedMapValues(modified) {
-			fmt.Printf("  - %s\n", id)
-		}
+	sections := []struct {
+		title string
+		ids   []string
+	}{
+		{"Updated", summary.Modified},
+		{"Added", summary.Added},
+		{"Removed", summary.Removed},
 	}
 
-	if len(added) > 0 {
-		fmt.Println("\nAdded:")
-		for _, id := range sortedMapValues(added) {
-			fmt.Printf("  - %s\n", id)
+	for _, section := range sections {
+		if len(section.ids) == 0 {
+			continue
 		}
-	}
-
-	if len(removed) > 0 {
-		fmt.Println("\nRemoved:")
-		for _, id := range sortedMapValues(removed) {
-			fmt.Printf("  - %s\n", id)
+		if _, err := fmt.Fprintf(w, "\n%s:\n", section.title); err != nil {
+			return err
+		}
+		for _, id := range section.ids {
+			if _, err := fmt.Fprintf(w, "  - %s\n", id); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/mozilla/mozcloud/tools/render-diff/internal/git"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/helm"
 )
 
 func TestGetRepoRoot(t *testing.T) {
@@ -69,7 +70,7 @@ func TestRenderManifests(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			output, err := RenderManifests(tc.path, tc.values, tc.debug, false, "test")
+			output, err := RenderManifests(tc.path, helm.RenderOptions{ReleaseName: "test", ValuesFiles: tc.values, Debug: tc.debug})
 
 			if (err != nil) != tc.wantErr {
 				t.Fatalf("RenderManifests() error = %v, wantErr %v", err, tc.wantErr)
@@ -118,3 +119,54 @@ func TestCreateDiff(t *testing.T) {
 		})
 	}
 }
+
+func TestSummarizeChanges(t *testing.T) {
+	target := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+data:
+  key: old
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: removed
+data:
+  key: value
+`
+	local := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+data:
+  key: new
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: added
+spec:
+  type: ClusterIP
+`
+
+	report, err := CompareRenders(target, local, "target", "local")
+	if err != nil {
+		t.Fatalf("CompareRenders() failed: %v", err)
+	}
+
+	summary := SummarizeChanges(report)
+
+	if summary.Total() != 3 {
+		t.Errorf("Total() = %d, want 3. Summary: %+v", summary.Total(), summary)
+	}
+
+	want := "3 changes (1 updated, 1 added, 1 removed)"
+	if got := summary.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	if got := (ChangeSummary{}).String(); got != "no changes" {
+		t.Errorf("empty String() = %q, want %q", got, "no changes")
+	}
+}
@@ -0,0 +1,288 @@
+// Package discover finds the Helm charts and Kustomizations in a repository
+// and works out which of them are affected by a set of changed files
+package discover
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kind is the type of a render root
+type Kind string
+
+const (
+	KindHelm      Kind = "helm"
+	KindKustomize Kind = "kustomize"
+)
+
+// kustomizationFiles are the file names kustomize accepts for a kustomization
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// Root is a directory that can be rendered on its own
+type Root struct {
+	// Path is relative to the repository root, using forward slashes
+	Path string
+	Kind Kind
+	// Values are the values files the root is rendered with, relative to
+	// the repository root. A change to any of them affects the root, even
+	// if they are outside of its directory.
+	Values []string
+}
+
+// Roots finds every Helm chart and Kustomization below scope, a directory
+// relative to repoRoot. Library charts cannot be rendered and are skipped,
+// as are subcharts vendored into a chart's charts/ directory.
+func Roots(repoRoot, scope string) ([]Root, error) {
+	var roots []Root
+
+	err := filepath.WalkDir(filepath.Join(repoRoot, scope), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(repoRoot, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Skip hidden directories such as .git, and vendored subcharts,
+		// which are rendered as part of their parent chart
+		name := d.Name()
+		if rel != filepath.ToSlash(filepath.Clean(scope)) && strings.HasPrefix(name, ".") {
+			return filepath.SkipDir
+		}
+		if name == "charts" && fileExists(filepath.Join(filepath.Dir(p), "Chart.yaml")) {
+			return filepath.SkipDir
+		}
+
+		if chart, ok, err := readChart(p); err != nil {
+			return err
+		} else if ok {
+			if chart.Type != "library" {
+				roots = append(roots, Root{Path: rel, Kind: KindHelm})
+			}
+			return nil
+		}
+		if kustomizationFile(p) != "" {
+			roots = append(roots, Root{Path: rel, Kind: KindKustomize})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for charts and kustomizations: %w", err)
+	}
+
+	return roots, nil
+}
+
+// Affected returns the roots that contain a changed file, that are rendered
+// with a changed values file, or that depend on a directory containing one.
+// Dependencies are followed transitively through file:// chart dependencies
+// and local kustomize resources, bases and components. Changed file paths
+// are relative to repoRoot.
+func Affected(repoRoot string, roots []Root, changed []string) ([]Root, error) {
+	a := &analyzer{
+		repoRoot: repoRoot,
+		changed:  changed,
+		memo:     make(map[string]bool),
+		visiting: make(map[string]bool),
+	}
+
+	var affected []Root
+	for _, root := range roots {
+		ok := slices.ContainsFunc(root.Values, a.touched)
+		if !ok {
+			var err error
+			if ok, err = a.affected(root.Path); err != nil {
+				return nil, err
+			}
+		}
+		if ok {
+			affected = append(affected, root)
+		}
+	}
+
+	sort.Slice(affected, func(i, j int) bool {
+		return affected[i].Path < affected[j].Path
+	})
+	return affected, nil
+}
+
+// Removed returns the roots of the target side that no longer exist on
+// the local side, e.g. charts deleted by the changes
+func Removed(local, target []Root) []Root {
+	var removed []Root
+	for _, root := range target {
+		if !slices.ContainsFunc(local, func(r Root) bool { return r.Path == root.Path }) {
+			removed = append(removed, root)
+		}
+	}
+	return removed
+}
+
+// analyzer memoizes which directories are affected by the changed files
+type analyzer struct {
+	repoRoot string
+	changed  []string
+	memo     map[string]bool
+	visiting map[string]bool
+}
+
+// affected reports whether dir, or anything it depends on, has changed
+func (a *analyzer) affected(dir string) (bool, error) {
+	if result, ok := a.memo[dir]; ok {
+		return result, nil
+	}
+	// Dependency cycles are invalid, but should not hang discovery
+	if a.visiting[dir] {
+		return false, nil
+	}
+	a.visiting[dir] = true
+	defer delete(a.visiting, dir)
+
+	if a.touched(dir) {
+		a.memo[dir] = true
+		return true, nil
+	}
+
+	deps, err := Dependencies(a.repoRoot, dir)
+	if err != nil {
+		return false, err
+	}
+	for _, dep := range deps {
+		ok, err := a.affected(dep)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			a.memo[dir] = true
+			return true, nil
+		}
+	}
+
+	a.memo[dir] = false
+	return false, nil
+}
+
+// touched reports whether a changed file is p or lies below p
+func (a *analyzer) touched(p string) bool {
+	for _, file := range a.changed {
+		if p == "." || file == p || strings.HasPrefix(file, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependencies returns the local paths a chart or kustomization in dir
+// depends on, relative to repoRoot. Remote dependencies are ignored.
+func Dependencies(repoRoot, dir string) ([]string, error) {
+	abs := filepath.Join(repoRoot, dir)
+
+	// Kustomize resources can be plain files, which have no dependencies
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+
+	var refs []string
+	if chart, ok, err := readChart(abs); err != nil {
+		return nil, err
+	} else if ok {
+		for _, dep := range chart.Dependencies {
+			if local, found := strings.CutPrefix(dep.Repository, "file://"); found {
+				refs = append(refs, local)
+			}
+		}
+	} else if file := kustomizationFile(abs); file != "" {
+		k, err := readKustomization(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range append(append(k.Resources, k.Bases...), k.Components...) {
+			// Remote resources are URLs, or git references with a query
+			if !strings.Contains(ref, "://") && !strings.Contains(ref, "?") {
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	deps := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		deps = append(deps, path.Join(dir, filepath.ToSlash(ref)))
+	}
+	return deps, nil
+}
+
+// chartFile holds the fields of Chart.yaml used for discovery
+type chartFile struct {
+	Type         string `yaml:"type"`
+	Dependencies []struct {
+		Repository string `yaml:"repository"`
+	} `yaml:"dependencies"`
+}
+
+// readChart reads the Chart.yaml in dir, if there is one
+func readChart(dir string) (chartFile, bool, error) {
+	var chart chartFile
+
+	b, err := os.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	if os.IsNotExist(err) {
+		return chart, false, nil
+	} else if err != nil {
+		return chart, false, fmt.Errorf("failed to read Chart.yaml: %w", err)
+	}
+
+	if err := yaml.Unmarshal(b, &chart); err != nil {
+		return chart, false, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, "Chart.yaml"), err)
+	}
+	return chart, true, nil
+}
+
+// kustomizationFields holds the fields of a kustomization used for discovery
+type kustomizationFields struct {
+	Resources  []string `yaml:"resources"`
+	Bases      []string `yaml:"bases"`
+	Components []string `yaml:"components"`
+}
+
+// readKustomization parses a kustomization file
+func readKustomization(file string) (kustomizationFields, error) {
+	var k kustomizationFields
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return k, fmt.Errorf("failed to read kustomization: %w", err)
+	}
+	if err := yaml.Unmarshal(b, &k); err != nil {
+		return k, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return k, nil
+}
+
+// kustomizationFile returns the kustomization file in dir, or an empty string
+func kustomizationFile(dir string) string {
+	for _, name := range kustomizationFiles {
+		if p := filepath.Join(dir, name); fileExists(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// fileExists reports whether p exists and is a regular file
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,155 @@
+package discover
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeRepo creates a repository layout from a map of file paths to contents
+func writeRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func testRepo(t *testing.T) string {
+	return writeRepo(t, map[string]string{
+		"charts/web/Chart.yaml":              "name: web\ndependencies:\n  - name: common\n    repository: file://../../libs/common\n  - name: redis\n    repository: https://charts.example.com\n",
+		"charts/web/values.yaml":             "",
+		"charts/web/charts/sub/Chart.yaml":   "name: sub\n",
+		"charts/api/Chart.yaml":              "name: api\n",
+		"charts/api/values-prod.yaml":        "",
+		"libs/common/Chart.yaml":             "name: common\ntype: library\n",
+		"libs/common/templates/_helpers.tpl": "",
+		"kustomize/base/kustomization.yaml":  "resources:\n  - deployment.yaml\n",
+		"kustomize/base/deployment.yaml":     "",
+		"kustomize/prod/kustomization.yaml":  "resources:\n  - ../base\n  - https://example.com/remote.yaml\n",
+		".hidden/Chart.yaml":                 "name: hidden\n",
+		"README.md":                          "",
+	})
+}
+
+func TestRoots(t *testing.T) {
+	repo := testRepo(t)
+
+	roots, err := Roots(repo, ".")
+	if err != nil {
+		t.Fatalf("Roots() failed: %v", err)
+	}
+
+	want := []Root{
+		{Path: "charts/api", Kind: KindHelm},
+		{Path: "charts/web", Kind: KindHelm},
+		{Path: "kustomize/base", Kind: KindKustomize},
+		{Path: "kustomize/prod", Kind: KindKustomize},
+	}
+	if !reflect.DeepEqual(roots, want) {
+		t.Errorf("Roots() = %v, want %v", roots, want)
+	}
+
+	scoped, err := Roots(repo, "kustomize")
+	if err != nil {
+		t.Fatalf("Roots() failed: %v", err)
+	}
+	if len(scoped) != 2 {
+		t.Errorf("Roots() with scope = %v, want the two kustomizations", scoped)
+	}
+}
+
+func TestAffected(t *testing.T) {
+	repo := testRepo(t)
+	roots, err := Roots(repo, ".")
+	if err != nil {
+		t.Fatalf("Roots() failed: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		changed []string
+		want    []string
+	}{
+		{name: "Chart file", changed: []string{"charts/api/values-prod.yaml"}, want: []string{"charts/api"}},
+		{name: "Vendored subchart", changed: []string{"charts/web/charts/sub/Chart.yaml"}, want: []string{"charts/web"}},
+		{name: "Library chart dependency", changed: []string{"libs/common/templates/_helpers.tpl"}, want: []string{"charts/web"}},
+		{name: "Kustomize base", changed: []string{"kustomize/base/deployment.yaml"}, want: []string{"kustomize/base", "kustomize/prod"}},
+		{name: "Similar prefix", changed: []string{"charts/apiserver/Chart.yaml"}, want: nil},
+		{name: "Unrelated file", changed: []string{"README.md"}, want: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			affected, err := Affected(repo, roots, tc.changed)
+			if err != nil {
+				t.Fatalf("Affected() failed: %v", err)
+			}
+
+			var got []string
+			for _, root := range affected {
+				got = append(got, root.Path)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Affected(%v) = %v, want %v", tc.changed, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAffectedCycle(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"a/kustomization.yaml": "resources:\n  - ../b\n",
+		"b/kustomization.yaml": "resources:\n  - ../a\n",
+		"c/file.yaml":          "",
+	})
+	roots, err := Roots(repo, ".")
+	if err != nil {
+		t.Fatalf("Roots() failed: %v", err)
+	}
+
+	affected, err := Affected(repo, roots, []string{"c/file.yaml"})
+	if err != nil {
+		t.Fatalf("Affected() failed: %v", err)
+	}
+	if len(affected) != 0 {
+		t.Errorf("Affected() = %v, want none", affected)
+	}
+}
+
+func TestAffectedValues(t *testing.T) {
+	repo := testRepo(t)
+	roots := []Root{
+		{Path: "charts/api", Kind: KindHelm, Values: []string{"common/values-prod.yaml"}},
+		{Path: "charts/web", Kind: KindHelm},
+	}
+
+	affected, err := Affected(repo, roots, []string{"common/values-prod.yaml"})
+	if err != nil {
+		t.Fatalf("Affected() failed: %v", err)
+	}
+	if len(affected) != 1 || affected[0].Path != "charts/api" {
+		t.Errorf("Affected() = %v, want charts/api", affected)
+	}
+}
+
+func TestRemoved(t *testing.T) {
+	local := []Root{{Path: "charts/api", Kind: KindHelm}}
+	target := []Root{
+		{Path: "charts/api", Kind: KindHelm},
+		{Path: "charts/old", Kind: KindHelm},
+	}
+
+	want := []Root{{Path: "charts/old", Kind: KindHelm}}
+	if got := Removed(local, target); !reflect.DeepEqual(got, want) {
+		t.Errorf("Removed() = %v, want %v", got, want)
+	}
+}
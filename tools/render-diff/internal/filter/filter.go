@@ -0,0 +1,144 @@
+// Package filter selects the objects of a rendered manifest that are diffed
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+)
+
+// Selector selects objects by kind, name and namespace. Every field holds
+// glob patterns, and an empty field matches every object. Kinds are
+// matched case-insensitively.
+type Selector struct {
+	IncludeKinds []string
+	ExcludeKinds []string
+	Names        []string
+	Namespaces   []string
+	// ReleaseNamespace is matched against Namespaces for objects without
+	// a namespace, which are installed into the release namespace.
+	// Defaults to "default".
+	ReleaseNamespace string
+}
+
+// Validate checks that every pattern is a valid glob
+func (s Selector) Validate() error {
+	for _, patterns := range [][]string{s.IncludeKinds, s.ExcludeKinds, s.Names, s.Namespaces} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether the selector matches every object
+func (s Selector) IsEmpty() bool {
+	return len(s.IncludeKinds) == 0 && len(s.ExcludeKinds) == 0 && len(s.Names) == 0 && len(s.Namespaces) == 0
+}
+
+// Matches reports whether an object is selected
+func (s Selector) Matches(id manifest.ResourceID) bool {
+	kind := strings.ToLower(id.Kind)
+	if len(s.IncludeKinds) > 0 && !matchAny(lower(s.IncludeKinds), kind) {
+		return false
+	}
+	if matchAny(lower(s.ExcludeKinds), kind) {
+		return false
+	}
+	if len(s.Names) > 0 && !matchAny(s.Names, id.Name) {
+		return false
+	}
+	if len(s.Namespaces) > 0 && !matchAny(s.Namespaces, s.namespace(id)) {
+		return false
+	}
+	return true
+}
+
+// namespace returns the namespace of an object, or the release namespace
+// if it does not set one
+func (s Selector) namespace(id manifest.ResourceID) string {
+	switch {
+	case id.Namespace != "":
+		return id.Namespace
+	case s.ReleaseNamespace != "":
+		return s.ReleaseNamespace
+	default:
+		return "default"
+	}
+}
+
+// String describes the selector for reports, e.g. "kind=Deployment name=web-*"
+func (s Selector) String() string {
+	var parts []string
+	add := func(key string, patterns []string) {
+		if len(patterns) > 0 {
+			parts = append(parts, key+"="+strings.Join(patterns, ","))
+		}
+	}
+	add("kind", s.IncludeKinds)
+	add("kind!", s.ExcludeKinds)
+	add("name", s.Names)
+	add("namespace", s.Namespaces)
+	return strings.Join(parts, " ")
+}
+
+// Apply removes the objects that are not selected from both renders.
+// It returns the filtered renders and the number of distinct objects
+// that were excluded.
+func Apply(target, local string, s Selector) (string, string, int, error) {
+	if s.IsEmpty() {
+		return target, local, 0, nil
+	}
+
+	excluded := make(map[string]bool)
+	filter := func(render string) (string, error) {
+		docs, err := manifest.Parse(render)
+		if err != nil {
+			return "", err
+		}
+
+		var kept []*manifest.Document
+		for _, doc := range docs {
+			if s.Matches(doc.ID) {
+				kept = append(kept, doc)
+			} else {
+				excluded[doc.ID.String()] = true
+			}
+		}
+		return manifest.Join(kept), nil
+	}
+
+	filteredTarget, err := filter(target)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to parse target render: %w", err)
+	}
+	filteredLocal, err := filter(local)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to parse local render: %w", err)
+	}
+
+	return filteredTarget, filteredLocal, len(excluded), nil
+}
+
+// matchAny reports whether value matches any of the patterns
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lower returns the patterns in lower case
+func lower(patterns []string) []string {
+	result := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		result[i] = strings.ToLower(pattern)
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+)
+
+func TestMatches(t *testing.T) {
+	deployment := manifest.ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "prod", Name: "web-api"}
+	route := manifest.ResourceID{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute", Namespace: "prod", Name: "web"}
+	configMap := manifest.ResourceID{Version: "v1", Kind: "ConfigMap", Name: "web-config"}
+
+	testCases := []struct {
+		name     string
+		selector Selector
+		want     []bool
+	}{
+		{name: "Empty selector", selector: Selector{}, want: []bool{true, true, true}},
+		{name: "Include kinds", selector: Selector{IncludeKinds: []string{"Deployment", "HTTPRoute"}}, want: []bool{true, true, false}},
+		{name: "Kinds are case-insensitive", selector: Selector{IncludeKinds: []string{"deployment"}}, want: []bool{true, false, false}},
+		{name: "Exclude kinds", selector: Selector{ExcludeKinds: []string{"Config*"}}, want: []bool{true, true, false}},
+		{name: "Name glob", selector: Selector{Names: []string{"web-*"}}, want: []bool{true, false, true}},
+		{name: "Namespace", selector: Selector{Namespaces: []string{"prod"}}, want: []bool{true, true, false}},
+		{name: "Unset namespace is the default namespace", selector: Selector{Namespaces: []string{"default"}}, want: []bool{false, false, true}},
+		{name: "Unset namespace is the release namespace", selector: Selector{Namespaces: []string{"prod"}, ReleaseNamespace: "prod"}, want: []bool{true, true, true}},
+		{name: "Combined", selector: Selector{IncludeKinds: []string{"*"}, ExcludeKinds: []string{"HTTPRoute"}, Names: []string{"web*"}, Namespaces: []string{"prod"}}, want: []bool{true, false, false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i, id := range []manifest.ResourceID{deployment, route, configMap} {
+				if got := tc.selector.Matches(id); got != tc.want[i] {
+					t.Errorf("Matches(%s) = %v, want %v", id, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Selector{Names: []string{"web-["}}).Validate(); err == nil {
+		t.Error("Validate() expected an error for an invalid pattern")
+	}
+	if err := (Selector{Names: []string{"web-*"}}).Validate(); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	target := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+`
+	local := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+`
+
+	gotTarget, gotLocal, excluded, err := Apply(target, local, Selector{IncludeKinds: []string{"Deployment"}})
+	if err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if excluded != 2 {
+		t.Errorf("Apply() excluded = %d, want 2", excluded)
+	}
+	for _, render := range []string{gotTarget, gotLocal} {
+		if strings.Contains(render, "ConfigMap") || !strings.Contains(render, "kind: Deployment") {
+			t.Errorf("Apply() did not filter the render:\n%s", render)
+		}
+	}
+
+	sameTarget, sameLocal, excluded, err := Apply(target, local, Selector{})
+	if err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if sameTarget != target || sameLocal != local || excluded != 0 {
+		t.Error("Apply() with an empty selector should return the renders unchanged")
+	}
+}
+
+func TestString(t *testing.T) {
+	s := Selector{IncludeKinds: []string{"Deployment", "HTTPRoute"}, Names: []string{"web-*"}}
+	if got, want := s.String(), "kind=Deployment,HTTPRoute name=web-*"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -2,6 +2,7 @@
 package git
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -12,14 +13,57 @@ import (
 const WorktreePrefix = "diff-ref-"
 
 func SetupWorkTree(repoRoot, gitRef string) (string, func(), error) {
+	tempDirs, cleanup, err := SetupWorkTrees(context.Background(), repoRoot, gitRef)
+	if err != nil {
+		return "", nil, err
+	}
+	return tempDirs[0], cleanup, nil
+}
+
+// SetupWorkTrees creates one temporary work tree per ref, in order, after a
+// single fetch. The returned cleanup function removes every work tree. If a
+// work tree cannot be created, or ctx is cancelled while they are being set
+// up, the work trees created so far are removed before returning the error.
+func SetupWorkTrees(ctx context.Context, repoRoot string, gitRefs ...string) ([]string, func(), error) {
 
 	// Fetch from all remotes
-	fetchCmd := exec.Command("git", "fetch", "--all")
+	fetchCmd := exec.CommandContext(ctx, "git", "fetch", "--all")
 	fetchCmd.Dir = repoRoot
 	if output, err := fetchCmd.CombinedOutput(); err != nil {
-		return "", nil, fmt.Errorf("failed to run 'git fetch --all': %w\nOutput: %s", err, string(output))
+		return nil, nil, fmt.Errorf("failed to run 'git fetch --all': %w\nOutput: %s", err, string(output))
 	}
 
+	var tempDirs []string
+	var cleanups []func()
+
+	// Combined cleanup for every work tree
+	// Returning this function to defer in rootCmd
+	cleanup := func() {
+		for _, c := range cleanups {
+			c()
+		}
+	}
+
+	for _, gitRef := range gitRefs {
+		if err := ctx.Err(); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("interrupted while setting up work trees: %w", err)
+		}
+
+		tempDir, c, err := addWorkTree(repoRoot, gitRef)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		tempDirs = append(tempDirs, tempDir)
+		cleanups = append(cleanups, c)
+	}
+
+	return tempDirs, cleanup, nil
+}
+
+// addWorkTree checks out gitRef into a new temporary directory
+func addWorkTree(repoRoot, gitRef string) (string, func(), error) {
 	// Set up a Git Worktree for gitref
 	tempDir, err := os.MkdirTemp("", WorktreePrefix)
 	if err != nil {
@@ -27,7 +71,6 @@ func SetupWorkTree(repoRoot, gitRef string) (string, func(), error) {
 	}
 
 	// Combined worktree and tempdir cleanup
-	// Returning this function to defer in rootCmd
 	cleanup := func() {
 		// Using --force to avoid errors if dir is already partially cleaned
 		cleanupCmd := exec.Command("git", "worktree", "remove", "--force", tempDir)
@@ -36,7 +79,7 @@ func SetupWorkTree(repoRoot, gitRef string) (string, func(), error) {
 			log.Printf("Warning: failed to run 'git worktree remove'. Manual cleanup may be required. Error: %v, Output: %s", err, string(output))
 		}
 		if err := os.RemoveAll(tempDir); err != nil {
-			fmt.Printf("error removing temporary directory %s: %v\n", tempDir, err)
+			log.Printf("error removing temporary directory %s: %v", tempDir, err)
 		}
 	}
 
@@ -45,12 +88,75 @@ func SetupWorkTree(repoRoot, gitRef string) (string, func(), error) {
 	addCmd := exec.Command("git", "worktree", "add", "-d", tempDir, gitRef)
 	addCmd.Dir = repoRoot
 	if output, err := addCmd.CombinedOutput(); err != nil {
+		_ = os.RemoveAll(tempDir)
 		return "", nil, fmt.Errorf("failed to create worktree for '%s': %v\nOutput: %s", gitRef, err, string(output))
 	}
 
 	return tempDir, cleanup, nil
 }
 
+// MergeBase returns the abbreviated hash of the best common ancestor of two
+// refs, like the base of a three-dot diff 'git diff a...b'
+func MergeBase(repoRoot, a, b string) (string, error) {
+	cmd := exec.Command("git", "merge-base", a, b)
+	cmd.Dir = repoRoot
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge base of '%s' and '%s': %w\nOutput: %s", a, b, err, string(output))
+	}
+
+	shortCmd := exec.Command("git", "rev-parse", "--short", strings.TrimSpace(string(output)))
+	shortCmd.Dir = repoRoot
+	short, err := shortCmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to abbreviate merge base: %w\nOutput: %s", err, string(short))
+	}
+	return strings.TrimSpace(string(short)), nil
+}
+
+// ChangedFiles lists the files that differ between two refs, relative to
+// the repository root. When to is empty, the working tree is compared
+// against from instead, including uncommitted and untracked files.
+// Renames are reported as a deletion and an addition.
+func ChangedFiles(repoRoot, from, to string) ([]string, error) {
+	args := []string{"diff", "--name-only", "--no-renames", from}
+	if to != "" {
+		args = append(args, to)
+	}
+	files, err := gitLines(repoRoot, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if to == "" {
+		untracked, err := gitLines(repoRoot, "ls-files", "--others", "--exclude-standard")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, untracked...)
+	}
+
+	return files, nil
+}
+
+// gitLines runs a git command in repoRoot and returns its non-empty output lines
+func gitLines(repoRoot string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run 'git %s': %w", strings.Join(args, " "), err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
 // GetRepoRoot finds the top-level directory of the current git repository.
 func GetRepoRoot() (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
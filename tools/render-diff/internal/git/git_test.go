@@ -1,7 +1,12 @@
 package git
 
 import (
+	"context"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -53,3 +58,111 @@ func TestSetupWorkTree(t *testing.T) {
 		}
 	})
 }
+
+func TestSetupWorkTrees(t *testing.T) {
+	repoRoot, _ := GetRepoRoot()
+
+	t.Run("Success with two refs", func(t *testing.T) {
+		tempDirs, cleanup, err := SetupWorkTrees(context.Background(), repoRoot, "HEAD", "HEAD")
+		if err != nil {
+			t.Fatalf("SetupWorkTrees() failed: %v", err)
+		}
+		if len(tempDirs) != 2 || tempDirs[0] == tempDirs[1] {
+			t.Fatalf("SetupWorkTrees() returned %v, want two distinct directories", tempDirs)
+		}
+
+		cleanup()
+		for _, dir := range tempDirs {
+			if _, err := os.Stat(dir); !os.IsNotExist(err) {
+				t.Errorf("Cleanup function failed: tempDir still exists: %s", dir)
+			}
+		}
+	})
+
+	t.Run("Failure removes earlier work trees", func(t *testing.T) {
+		before := worktreeCount(t, repoRoot)
+
+		tempDirs, cleanup, err := SetupWorkTrees(context.Background(), repoRoot, "HEAD", "this-ref-does-not-exist-12345")
+		if err == nil {
+			t.Fatal("SetupWorkTrees() with invalid ref succeeded, but expected an error")
+		}
+		if cleanup != nil || tempDirs != nil {
+			t.Error("SetupWorkTrees() returned work trees on failure")
+		}
+		if after := worktreeCount(t, repoRoot); after != before {
+			t.Errorf("SetupWorkTrees() left %d work trees behind", after-before)
+		}
+	})
+
+	t.Run("Failure with cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, _, err := SetupWorkTrees(ctx, repoRoot, "HEAD", "HEAD"); err == nil {
+			t.Fatal("SetupWorkTrees() with cancelled context succeeded, but expected an error")
+		}
+	})
+}
+
+func TestMergeBase(t *testing.T) {
+	repoRoot, _ := GetRepoRoot()
+
+	head := exec.Command("git", "rev-parse", "--short", "HEAD")
+	head.Dir = repoRoot
+	want, err := head.Output()
+	if err != nil {
+		t.Fatalf("git rev-parse failed: %v", err)
+	}
+
+	got, err := MergeBase(repoRoot, "HEAD", "HEAD")
+	if err != nil {
+		t.Fatalf("MergeBase() failed: %v", err)
+	}
+	if got != strings.TrimSpace(string(want)) {
+		t.Errorf("MergeBase(HEAD, HEAD) = %q, want %q", got, strings.TrimSpace(string(want)))
+	}
+
+	if _, err := MergeBase(repoRoot, "HEAD", "this-ref-does-not-exist-12345"); err == nil {
+		t.Error("MergeBase() with invalid ref succeeded, but expected an error")
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	repoRoot, _ := GetRepoRoot()
+
+	untracked, err := os.CreateTemp(repoRoot, "changed-files-test-*.txt")
+	if err != nil {
+		t.Fatalf("failed to create untracked file: %v", err)
+	}
+	_ = untracked.Close()
+	defer func() { _ = os.Remove(untracked.Name()) }()
+
+	files, err := ChangedFiles(repoRoot, "HEAD", "")
+	if err != nil {
+		t.Fatalf("ChangedFiles() failed: %v", err)
+	}
+	rel, _ := filepath.Rel(repoRoot, untracked.Name())
+	if !slices.Contains(files, rel) {
+		t.Errorf("ChangedFiles() = %v, want it to contain untracked file %s", files, rel)
+	}
+
+	files, err = ChangedFiles(repoRoot, "HEAD", "HEAD")
+	if err != nil {
+		t.Fatalf("ChangedFiles() failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("ChangedFiles(HEAD, HEAD) = %v, want no files", files)
+	}
+}
+
+// worktreeCount returns the number of work trees registered in the repository
+func worktreeCount(t *testing.T, repoRoot string) int {
+	t.Helper()
+	cmd := exec.Command("git", "worktree", "list", "--porcelain")
+	cmd.Dir = repoRoot
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git worktree list failed: %v", err)
+	}
+	return strings.Count(string(output), "worktree ")
+}
@@ -0,0 +1,64 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// CapabilitiesFile describes the cluster a chart is rendered for,
+// e.g. the Kubernetes version and 'kubectl api-versions' of a GKE cluster
+type CapabilitiesFile struct {
+	KubeVersion string `yaml:"kubeVersion"`
+	// APIVersions replaces Helm's built in list of API versions when set.
+	// Entries are "group/version" or "group/version/Kind".
+	APIVersions []string `yaml:"apiVersions"`
+}
+
+// LoadCapabilitiesFile reads a capabilities file
+func LoadCapabilitiesFile(filename string) (CapabilitiesFile, error) {
+	var file CapabilitiesFile
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return file, fmt.Errorf("failed to read capabilities file: %w", err)
+	}
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return file, fmt.Errorf("failed to parse capabilities file %s: %w", filename, err)
+	}
+	return file, nil
+}
+
+// Capabilities builds the capabilities templates see as .Capabilities,
+// starting from Helm's defaults like 'helm template'. The file is applied
+// first, then kubeVersion replaces its version and apiVersions are added
+// to its API versions, like the --kube-version and --api-versions flags
+// of 'helm template'. Either argument can be empty.
+func Capabilities(file CapabilitiesFile, kubeVersion string, apiVersions []string) (*chartutil.Capabilities, error) {
+	caps := &chartutil.Capabilities{
+		KubeVersion: chartutil.DefaultCapabilities.KubeVersion,
+		APIVersions: slices.Clone(chartutil.DefaultCapabilities.APIVersions),
+		HelmVersion: chartutil.DefaultCapabilities.HelmVersion,
+	}
+
+	if kubeVersion == "" {
+		kubeVersion = file.KubeVersion
+	}
+	if kubeVersion != "" {
+		version, err := chartutil.ParseKubeVersion(kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kube version %q: %w", kubeVersion, err)
+		}
+		caps.KubeVersion = *version
+	}
+
+	if len(file.APIVersions) > 0 {
+		caps.APIVersions = slices.Clone(chartutil.VersionSet(file.APIVersions))
+	}
+	caps.APIVersions = append(caps.APIVersions, apiVersions...)
+
+	return caps, nil
+}
@@ -0,0 +1,125 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestCapabilities(t *testing.T) {
+	gke, err := LoadCapabilitiesFile("testdata/gke.yaml")
+	if err != nil {
+		t.Fatalf("LoadCapabilitiesFile failed: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		file        CapabilitiesFile
+		kubeVersion string
+		apiVersions []string
+		wantVersion string
+		wantHas     []string
+		wantMissing []string
+		wantErr     bool
+	}{
+		{
+			name:        "defaults",
+			wantVersion: chartutil.DefaultCapabilities.KubeVersion.Version,
+			wantHas:     []string{"apps/v1"},
+			wantMissing: []string{"monitoring.googleapis.com/v1"},
+		},
+		{
+			name:        "kube version flag",
+			kubeVersion: "1.31",
+			wantVersion: "v1.31",
+		},
+		{
+			name:        "extra api versions",
+			apiVersions: []string{"monitoring.googleapis.com/v1"},
+			wantVersion: chartutil.DefaultCapabilities.KubeVersion.Version,
+			wantHas:     []string{"apps/v1", "monitoring.googleapis.com/v1"},
+		},
+		{
+			name:        "file replaces api versions",
+			file:        gke,
+			wantVersion: "v1.30.5",
+			wantHas:     []string{"apps/v1", "monitoring.googleapis.com/v1/PodMonitoring"},
+			wantMissing: []string{"batch/v1"},
+		},
+		{
+			name:        "flags override file",
+			file:        gke,
+			kubeVersion: "v1.29.1",
+			apiVersions: []string{"batch/v1"},
+			wantVersion: "v1.29.1",
+			wantHas:     []string{"batch/v1", "monitoring.googleapis.com/v1"},
+		},
+		{
+			name:        "invalid kube version",
+			kubeVersion: "latest",
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			caps, err := Capabilities(tc.file, tc.kubeVersion, tc.apiVersions)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Capabilities() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if caps.KubeVersion.Version != tc.wantVersion {
+				t.Errorf("KubeVersion = %q, want %q", caps.KubeVersion.Version, tc.wantVersion)
+			}
+			for _, v := range tc.wantHas {
+				if !caps.APIVersions.Has(v) {
+					t.Errorf("APIVersions missing %q", v)
+				}
+			}
+			for _, v := range tc.wantMissing {
+				if caps.APIVersions.Has(v) {
+					t.Errorf("APIVersions unexpectedly has %q", v)
+				}
+			}
+		})
+	}
+
+	// Extra API versions must not leak into Helm's defaults
+	if chartutil.DefaultCapabilities.APIVersions.Has("monitoring.googleapis.com/v1") {
+		t.Errorf("default capabilities were modified")
+	}
+}
+
+func TestRenderChartCapabilities(t *testing.T) {
+	caps, err := Capabilities(CapabilitiesFile{}, "v1.30.5-gke.1014001", []string{"monitoring.googleapis.com/v1/PodMonitoring"})
+	if err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+
+	output, err := RenderChart("testdata/capabilities", RenderOptions{
+		ReleaseName:  "web",
+		Namespace:    "web-prod",
+		Capabilities: caps,
+	})
+	if err != nil {
+		t.Fatalf("RenderChart failed: %v", err)
+	}
+
+	for _, want := range []string{"namespace: web-prod", `kubeVersion: "v1.30.5"`, `monitoring: "gmp"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q. Got:\n%s", want, output)
+		}
+	}
+
+	output, err = RenderChart("testdata/capabilities", RenderOptions{ReleaseName: "web"})
+	if err != nil {
+		t.Fatalf("RenderChart failed: %v", err)
+	}
+	if !strings.Contains(output, "namespace: default") || strings.Contains(output, "monitoring:") {
+		t.Errorf("default render used unexpected capabilities. Got:\n%s", output)
+	}
+}
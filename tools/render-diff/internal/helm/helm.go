@@ -22,109 +22,82 @@ import (
 	"helm.sh/helm/v3/pkg/engine"
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/strvals"
 )
 
 var logMutex sync.Mutex
 
-// renderChart loads, merges values, and renders a Helm chart
-func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool, update bool) (string, error) {
-	chart, err := loadChart(chartPath, debug)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return "", err
-		}
-		return "", fmt.Errorf("failed to load chart from %s: %w", chartPath, err)
-	}
-
-	// Helm Dependency Build
-	// Run 'helm dependency build' if dependencies are present
-	if chart.Metadata.Dependencies != nil {
-
-		// We will silently load dependencies for the target ref chart as well, but want
-		// to log loading chart dependencies for the local ref
-		if !strings.Contains(chartPath, git.WorktreePrefix) {
-			logMutex.Lock()
-			log.Printf("Chart dependencies found. Loading dependencies from %s/Chart.lock", chartPath)
-			logMutex.Unlock()
-		}
-
-		if inflatedSubCharts(chartPath) {
-			logMutex.Lock()
-			log.Printf("Warning: inflated subcharts found in %s/charts.", chartPath)
-			logMutex.Unlock()
-		}
-
-		// We need a basic cli.EnvSettings to init the getter.Providers.
-		settings := cli.New()
-		settings.Debug = debug // Setting debug to match flag
-
-		getters := getter.All(settings)
-
-		// Create a registry client for OCI dependencies
-		registryClient, err := registry.NewClient(
-			registry.ClientOptDebug(settings.Debug),
-			registry.ClientOptEnableCache(true),
-			registry.ClientOptWriter(io.Discard),
-			registry.ClientOptCredentialsFile(settings.RegistryConfig),
-		)
-		if err != nil {
-			return "", fmt.Errorf("failed to create registry client: %w", err)
-		}
-
-		// Create a downloader manager.
-		man := downloader.Manager{
-			Out:            io.Discard,
-			ChartPath:      chartPath,
-			Getters:        getters,
-			RegistryClient: registryClient,
-			Debug:          debug,
-		}
-
-		// Run update. This updates the Chart.lock file if dependencies have changed.
-		// Only used if the -u flag is passed.
-		if update {
-			err = silentRun(debug, func() error {
-				return man.Update()
-			})
-			if err != nil {
-				return "", fmt.Errorf("failed to run dependency update: %w", err)
-			}
-		}
+// buildLocks serializes loading charts and building their dependencies
+// per chart directory. Matrix renders share a chart directory and would
+// otherwise read and write the same charts/ folder concurrently.
+var buildLocks sync.Map
+
+// lockChartPath locks the dependency build mutex for chartPath
+// and returns the matching unlock function
+func lockChartPath(chartPath string) func() {
+	mu, _ := buildLocks.LoadOrStore(filepath.Clean(chartPath), &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
 
-		// Run build. This downloads charts into the 'charts/' directory.
-		// We are ignoring some log output here, which can be reverted with the --debug flag
-		err = silentRun(debug, func() error {
-			return man.Build()
-		})
-		if err != nil {
-			return "", fmt.Errorf("failed to run dependency build: %w", err)
-		}
+// RenderOptions holds the settings used to render a chart, mirroring
+// the flags of 'helm template'
+type RenderOptions struct {
+	ReleaseName string
+	// Namespace is the release namespace. Defaults to "default".
+	Namespace   string
+	ValuesFiles []string
+	// Values are merged over the values files in order, e.g. the
+	// inline values of an Argo CD Application
+	Values []map[string]any
+	// SetJSON, Set, SetString and SetFile hold "key=value" parameters,
+	// like the matching 'helm template' flags. They are applied last,
+	// in that order. SetFile values are paths to files to read.
+	SetJSON   []string
+	Set       []string
+	SetString []string
+	SetFile   []string
+	// Capabilities are the cluster capabilities templates see as
+	// .Capabilities. Helm's defaults are used when nil.
+	Capabilities *chartutil.Capabilities
+	Debug        bool
+	Update       bool
+}
 
-		// Reload the chart after building dependencies
-		// This ensures the newly downloaded subcharts are included in the render.
-		chart, err = loadChart(chartPath, debug)
-		if err != nil {
-			return "", fmt.Errorf("failed to reload chart after dependency build: %w", err)
-		}
+// renderChart loads, merges values, and renders a Helm chart
+func RenderChart(chartPath string, opts RenderOptions) (string, error) {
+	chart, err := loadChartWithDependencies(chartPath, opts.Debug, opts.Update)
+	if err != nil {
+		return "", err
 	}
 
 	// Load additional values files from the --values flags
-	userValues, err := loadValues(valuesFiles)
+	userValues, err := loadValues(opts.ValuesFiles)
 	if err != nil {
 		return "", fmt.Errorf("failed to load/merge values: %w", err)
 	}
 
+	userValues, err = mergeOverrides(userValues, opts)
+	if err != nil {
+		return "", err
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
 	// Define release options for the render
 	options := chartutil.ReleaseOptions{
-		Name:      releaseName, // We don't need a real releaseName or namespace for the diff
-		Namespace: "default",
+		Name:      opts.ReleaseName, // We don't need a real releaseName for the diff
+		Namespace: namespace,
 		Revision:  1,
 		IsInstall: true,
 	}
 
 	// Get render values. This merges the chart's default values (from chart.Values/values.yaml)
 	// with the user-supplied values (from userValues).
-	renderVals, err := chartutil.ToRenderValues(chart, userValues, options, nil)
+	renderVals, err := chartutil.ToRenderValues(chart, userValues, options, opts.Capabilities)
 	if err != nil {
 		return "", fmt.Errorf("failed to prepare render values: %w", err)
 	}
@@ -160,6 +133,97 @@ func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool
 	return builder.String(), nil
 }
 
+// loadChartWithDependencies loads a chart and runs 'helm dependency build'
+// if it has dependencies. The chart directory is locked while charts/ is
+// read and rebuilt, but not while the chart is rendered, so renders of the
+// same chart still run in parallel.
+func loadChartWithDependencies(chartPath string, debug, update bool) (*chart.Chart, error) {
+	unlock := lockChartPath(chartPath)
+	defer unlock()
+
+	chart, err := loadChart(chartPath, debug)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load chart from %s: %w", chartPath, err)
+	}
+
+	// Helm Dependency Build
+	// Run 'helm dependency build' if dependencies are present
+	if chart.Metadata.Dependencies == nil {
+		return chart, nil
+	}
+
+	// We will silently load dependencies for the target ref chart as well, but want
+	// to log loading chart dependencies for the local ref
+	if !strings.Contains(chartPath, git.WorktreePrefix) {
+		logMutex.Lock()
+		log.Printf("Chart dependencies found. Loading dependencies from %s/Chart.lock", chartPath)
+		logMutex.Unlock()
+	}
+
+	if inflatedSubCharts(chartPath) {
+		logMutex.Lock()
+		log.Printf("Warning: inflated subcharts found in %s/charts.", chartPath)
+		logMutex.Unlock()
+	}
+
+	// We need a basic cli.EnvSettings to init the getter.Providers.
+	settings := cli.New()
+	settings.Debug = debug // Setting debug to match flag
+
+	getters := getter.All(settings)
+
+	// Create a registry client for OCI dependencies
+	registryClient, err := registry.NewClient(
+		registry.ClientOptDebug(settings.Debug),
+		registry.ClientOptEnableCache(true),
+		registry.ClientOptWriter(io.Discard),
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	// Create a downloader manager.
+	man := downloader.Manager{
+		Out:            io.Discard,
+		ChartPath:      chartPath,
+		Getters:        getters,
+		RegistryClient: registryClient,
+		Debug:          debug,
+	}
+
+	// Run update. This updates the Chart.lock file if dependencies have changed.
+	// Only used if the -u flag is passed.
+	if update {
+		err = silentRun(debug, func() error {
+			return man.Update()
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to run dependency update: %w", err)
+		}
+	}
+
+	// Run build. This downloads charts into the 'charts/' directory.
+	// We are ignoring some log output here, which can be reverted with the --debug flag
+	err = silentRun(debug, func() error {
+		return man.Build()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run dependency build: %w", err)
+	}
+
+	// Reload the chart after building dependencies
+	// This ensures the newly downloaded subcharts are included in the render.
+	chart, err = loadChart(chartPath, debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload chart after dependency build: %w", err)
+	}
+	return chart, nil
+}
+
 // loadValues merges multiple values files in order, mimicking 'helm -f file1 -f file2'
 func loadValues(valuesFiles []string) (chartutil.Values, error) {
 	mergedValues := chartutil.Values{}
@@ -186,6 +250,73 @@ func loadValues(valuesFiles []string) (chartutil.Values, error) {
 	return mergedValues, nil
 }
 
+// mergeOverrides merges inline values and then --set style parameters
+// over the values loaded from files, in the same order as Helm
+func mergeOverrides(base chartutil.Values, opts RenderOptions) (chartutil.Values, error) {
+	for _, values := range opts.Values {
+		// CoalesceTables writes into its first argument, and the same
+		// values are shared by concurrent renders, so merge into a copy
+		base = chartutil.CoalesceTables(copyValues(values), base)
+	}
+
+	for _, value := range opts.SetJSON {
+		if err := strvals.ParseJSON(value, base); err != nil {
+			return nil, fmt.Errorf("failed to parse set-json value %q: %w", value, err)
+		}
+	}
+	for _, value := range opts.Set {
+		if err := strvals.ParseInto(value, base); err != nil {
+			return nil, fmt.Errorf("failed to parse set value %q: %w", value, err)
+		}
+	}
+	for _, value := range opts.SetString {
+		if err := strvals.ParseIntoString(value, base); err != nil {
+			return nil, fmt.Errorf("failed to parse set-string value %q: %w", value, err)
+		}
+	}
+	for _, value := range opts.SetFile {
+		if err := strvals.ParseIntoFile(value, base, readSetFile); err != nil {
+			return nil, fmt.Errorf("failed to parse set-file value %q: %w", value, err)
+		}
+	}
+
+	return base, nil
+}
+
+// readSetFile reads the file named by a --set-file value as a string
+func readSetFile(rs []rune) (any, error) {
+	b, err := os.ReadFile(string(rs))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// copyValues returns a deep copy of a values map
+func copyValues(values map[string]any) map[string]any {
+	result := make(map[string]any, len(values))
+	for k, v := range values {
+		result[k] = copyValue(v)
+	}
+	return result
+}
+
+// copyValue returns a deep copy of a single value
+func copyValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return copyValues(v)
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = copyValue(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
 // IsHelmChart will try to load the path as a Helm Chart, if it fails we'll return false
 func IsHelmChart(path string) bool {
 	_, err := loadChart(path, false)
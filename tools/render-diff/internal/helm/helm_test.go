@@ -1,7 +1,11 @@
 package helm
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -48,7 +52,7 @@ func TestRenderChart(t *testing.T) {
 		debug := false // Test the silent path
 		update := false
 
-		output, err := RenderChart(chartPath, releaseName, valuesFiles, debug, update)
+		output, err := RenderChart(chartPath, RenderOptions{ReleaseName: releaseName, ValuesFiles: valuesFiles, Debug: debug, Update: update})
 		if err != nil {
 			t.Fatalf("RenderChart failed: %v", err)
 		}
@@ -78,7 +82,7 @@ func TestRenderChart(t *testing.T) {
 		debug := false // Test the silent path
 		update := false
 
-		output, err := RenderChart(chartPath, releaseName, valuesFiles, debug, update)
+		output, err := RenderChart(chartPath, RenderOptions{ReleaseName: releaseName, ValuesFiles: valuesFiles, Debug: debug, Update: update})
 		if err != nil {
 			t.Fatalf("RenderChart failed: %v", err)
 		}
@@ -101,7 +105,7 @@ func TestRenderChart(t *testing.T) {
 		debug := false // Test the silent path
 		update := true
 
-		output, err := RenderChart(chartPath, releaseName, valuesFiles, debug, update)
+		output, err := RenderChart(chartPath, RenderOptions{ReleaseName: releaseName, ValuesFiles: valuesFiles, Debug: debug, Update: update})
 		if err != nil {
 			t.Fatalf("RenderChart failed: %v", err)
 		}
@@ -121,3 +125,114 @@ func TestRenderChart(t *testing.T) {
 		}
 	})
 }
+
+func TestMergeOverrides(t *testing.T) {
+	inline := map[string]any{"image": map[string]any{"tag": "v2"}, "replicas": 2}
+	opts := RenderOptions{
+		Values:    []map[string]any{inline},
+		Set:       []string{"replicas=3", "ingress.enabled=true"},
+		SetString: []string{"image.tag=1234"},
+	}
+
+	base := map[string]any{"image": map[string]any{"repository": "nginx", "tag": "v1"}, "replicas": 1}
+	got, err := mergeOverrides(base, opts)
+	if err != nil {
+		t.Fatalf("mergeOverrides() failed: %v", err)
+	}
+
+	want := map[string]any{
+		"image":    map[string]any{"repository": "nginx", "tag": "1234"},
+		"replicas": int64(3),
+		"ingress":  map[string]any{"enabled": true},
+	}
+	if !reflect.DeepEqual(map[string]any(got), want) {
+		t.Errorf("mergeOverrides() = %v, want %v", got, want)
+	}
+
+	// Inline values are shared between renders and must not be modified
+	if _, ok := inline["image"].(map[string]any)["repository"]; ok {
+		t.Error("mergeOverrides() modified the inline values")
+	}
+
+	if _, err := mergeOverrides(map[string]any{}, RenderOptions{Set: []string{"a.=b"}}); err == nil {
+		t.Error("mergeOverrides() expected an error for an invalid set value")
+	}
+}
+
+func TestMergeOverridesFlags(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(certFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		opts    RenderOptions
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name: "set-json",
+			opts: RenderOptions{SetJSON: []string{`resources={"limits":{"cpu":"500m"}}`}},
+			want: map[string]any{"resources": map[string]any{"limits": map[string]any{"cpu": "500m"}}},
+		},
+		{
+			name: "set-file reads the file contents",
+			opts: RenderOptions{SetFile: []string{"tls.ca=" + certFile}},
+			want: map[string]any{"tls": map[string]any{"ca": "-----BEGIN CERTIFICATE-----\n"}},
+		},
+		{
+			name: "set overrides set-json, like helm",
+			opts: RenderOptions{
+				SetJSON: []string{`image={"tag":"v1","pullPolicy":"Always"}`},
+				Set:     []string{"image.tag=v2"},
+			},
+			want: map[string]any{"image": map[string]any{"tag": "v2", "pullPolicy": "Always"}},
+		},
+		{
+			name: "set-file overrides set-string",
+			opts: RenderOptions{
+				SetString: []string{"tls.ca=none"},
+				SetFile:   []string{"tls.ca=" + certFile},
+			},
+			want: map[string]any{"tls": map[string]any{"ca": "-----BEGIN CERTIFICATE-----\n"}},
+		},
+		{
+			name:    "missing set-file",
+			opts:    RenderOptions{SetFile: []string{"tls.ca=does-not-exist.crt"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid set-json",
+			opts:    RenderOptions{SetJSON: []string{"image={"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mergeOverrides(map[string]any{}, tc.opts)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("mergeOverrides() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(map[string]any(got), tc.want) {
+				t.Errorf("mergeOverrides() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadChartWithDependenciesUnlocks(t *testing.T) {
+	chartPath := "testdata/capabilities"
+	if _, err := loadChartWithDependencies(chartPath, false, false); err != nil {
+		t.Fatalf("loadChartWithDependencies() failed: %v", err)
+	}
+
+	// The lock must be released before the chart is rendered, so
+	// renders of the same chart are not serialized
+	mu, _ := buildLocks.Load(filepath.Clean(chartPath))
+	if !mu.(*sync.Mutex).TryLock() {
+		t.Fatal("chart directory is still locked after loading")
+	}
+	mu.(*sync.Mutex).Unlock()
+}
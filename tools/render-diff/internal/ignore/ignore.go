@@ -0,0 +1,236 @@
+// Package ignore removes noisy fields, such as chart version labels and
+// checksum annotations, from rendered manifests before they are diffed
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule ignores the fields selected by Path. Kind and Name optionally limit
+// the rule to matching objects, and both accept glob patterns.
+type Rule struct {
+	Path string `yaml:"path"`
+	Kind string `yaml:"kind,omitempty"`
+	Name string `yaml:"name,omitempty"`
+
+	path manifest.Path
+}
+
+// File is the layout of an ignore rules file
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// ParseRule parses an --ignore value. It accepts a path on its own, or a
+// path scoped to a kind and optionally a name as "Kind[/name]:path", e.g.
+// "Deployment/web:/metadata/annotations/checksum~1*".
+func ParseRule(spec string) (Rule, error) {
+	rule := Rule{Path: spec}
+
+	// A colon is only treated as a scope separator when it is followed by a
+	// path, so keys containing colons can still be used in unscoped rules
+	if scope, p, found := strings.Cut(spec, ":"); found && isPathStart(p) {
+		rule.Path = p
+		rule.Kind, rule.Name, _ = strings.Cut(scope, "/")
+		if rule.Kind == "" {
+			return Rule{}, fmt.Errorf("invalid ignore rule %q: kind is empty", spec)
+		}
+	}
+
+	if err := rule.compile(); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// LoadFile reads ignore rules from a YAML file
+func LoadFile(filename string) ([]Rule, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+
+	var file File
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse ignore file %s: %w", filename, err)
+	}
+
+	for i := range file.Rules {
+		if err := file.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid rule in %s: %w", filename, err)
+		}
+	}
+	return file.Rules, nil
+}
+
+// compile parses the rule's path and checks its scope patterns
+func (r *Rule) compile() error {
+	p, err := manifest.ParsePath(r.Path)
+	if err != nil {
+		return fmt.Errorf("invalid ignore rule %q: %w", r.Path, err)
+	}
+	for _, pattern := range []string{r.Kind, r.Name} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ignore rule %q: bad pattern %q: %w", r.Path, pattern, err)
+		}
+	}
+
+	r.path = p
+	return nil
+}
+
+// Matches reports whether the rule applies to an object
+func (r Rule) Matches(id manifest.ResourceID) bool {
+	if r.Kind != "" {
+		if ok, _ := path.Match(r.Kind, id.Kind); !ok {
+			return false
+		}
+	}
+	if r.Name != "" {
+		if ok, _ := path.Match(r.Name, id.Name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply removes the fields selected by the rules from both renders. It
+// returns the filtered renders and the number of changed fields that were
+// suppressed, i.e. ignored fields that differ between the two renders.
+func Apply(target, local string, rules []Rule) (string, string, int, error) {
+	if len(rules) == 0 {
+		return target, local, 0, nil
+	}
+
+	targetDocs, err := manifest.Parse(target)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to parse target render: %w", err)
+	}
+	localDocs, err := manifest.Parse(local)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to parse local render: %w", err)
+	}
+
+	suppressed := 0
+	targetIndex := manifest.Index(targetDocs)
+	for _, doc := range localDocs {
+		// Changes are only counted for objects present in both renders,
+		// added and removed objects are reported as a whole anyway
+		if other, ok := targetIndex[doc.ID.String()]; ok {
+			suppressed += countChanges(other, doc, rules)
+		}
+	}
+
+	changed := make(map[string]bool)
+	for _, docs := range [][]*manifest.Document{targetDocs, localDocs} {
+		for _, doc := range docs {
+			removed, err := remove(doc, rules)
+			if err != nil {
+				return "", "", 0, err
+			}
+			if removed {
+				changed[doc.ID.String()] = true
+			}
+		}
+	}
+
+	// Changed documents are re-encoded, so the other side of each changed
+	// object is re-encoded too. Otherwise a field ignored on one side only
+	// shows the encoder's indentation as a change in the plain diff.
+	for _, docs := range [][]*manifest.Document{targetDocs, localDocs} {
+		for _, doc := range docs {
+			if !changed[doc.ID.String()] {
+				continue
+			}
+			if err := doc.Update(); err != nil {
+				return "", "", 0, err
+			}
+		}
+	}
+
+	return joinRender(target, targetDocs), joinRender(local, localDocs), suppressed, nil
+}
+
+// countChanges counts the ignored fields that differ between two versions of an object
+func countChanges(target, local *manifest.Document, rules []Rule) int {
+	targetValues := make(map[string]*yaml.Node)
+	localValues := make(map[string]*yaml.Node)
+	for _, rule := range rules {
+		if !rule.Matches(local.ID) {
+			continue
+		}
+		for _, m := range rule.path.Find(target.Node) {
+			targetValues[m.Path] = m.Node
+		}
+		for _, m := range rule.path.Find(local.Node) {
+			localValues[m.Path] = m.Node
+		}
+	}
+
+	count := 0
+	for p, node := range localValues {
+		other, ok := targetValues[p]
+		if !ok || !equal(other, node) {
+			count++
+		}
+	}
+	for p := range targetValues {
+		if _, ok := localValues[p]; !ok {
+			count++
+		}
+	}
+	return count
+}
+
+// remove deletes the fields selected by the matching rules from a document
+// and reports whether any field was removed
+func remove(doc *manifest.Document, rules []Rule) (bool, error) {
+	nodes := make(map[*yaml.Node]bool)
+	for _, rule := range rules {
+		if !rule.Matches(doc.ID) {
+			continue
+		}
+		for _, m := range rule.path.Find(doc.Node) {
+			nodes[m.Node] = true
+		}
+	}
+	if len(nodes) == 0 {
+		return false, nil
+	}
+
+	manifest.Remove(doc.Node, nodes)
+	return true, nil
+}
+
+// joinRender joins the documents back together, keeping an empty
+// render empty so a missing target still diffs as a new addition
+func joinRender(render string, docs []*manifest.Document) string {
+	if strings.TrimSpace(render) == "" {
+		return render
+	}
+	return manifest.Join(docs)
+}
+
+// equal compares the decoded values of two nodes
+func equal(a, b *yaml.Node) bool {
+	var va, vb any
+	if err := a.Decode(&va); err != nil {
+		return false
+	}
+	if err := b.Decode(&vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// isPathStart reports whether s looks like the start of a path
+func isPathStart(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, "$") || strings.HasPrefix(s, ".")
+}
@@ -0,0 +1,219 @@
+package ignore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+)
+
+const targetRender = `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    helm.sh/chart: web-1.0.0
+spec:
+  replicas: 1
+  template:
+    metadata:
+      annotations:
+        checksum/config: abc
+---
+# Source: web/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  labels:
+    helm.sh/chart: web-1.0.0
+data:
+  key: value
+`
+
+const localRender = `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    helm.sh/chart: web-1.1.0
+spec:
+  replicas: 2
+  template:
+    metadata:
+      annotations:
+        checksum/config: def
+---
+# Source: web/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  labels:
+    helm.sh/chart: web-1.1.0
+data:
+  key: value
+`
+
+func TestParseRule(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		wantPath string
+		wantKind string
+		wantName string
+		wantErr  bool
+	}{
+		{spec: "/metadata/labels/helm.sh~1chart", wantPath: "/metadata/labels/helm.sh~1chart"},
+		{spec: "Deployment:/spec/replicas", wantPath: "/spec/replicas", wantKind: "Deployment"},
+		{spec: "Deployment/web-*:$.spec.replicas", wantPath: "$.spec.replicas", wantKind: "Deployment", wantName: "web-*"},
+		{spec: "metadata.annotations['example.com:key']", wantPath: "metadata.annotations['example.com:key']"},
+		{spec: "/data/a:b", wantPath: "/data/a:b"},
+		{spec: ":/spec/replicas", wantErr: true},
+		{spec: "Deployment:", wantPath: "Deployment:"},
+		{spec: "", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			rule, err := ParseRule(tc.spec)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseRule(%q) error = %v, wantErr %v", tc.spec, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if rule.Path != tc.wantPath || rule.Kind != tc.wantKind || rule.Name != tc.wantName {
+				t.Errorf("ParseRule(%q) = {%q %q %q}, want {%q %q %q}", tc.spec, rule.Path, rule.Kind, rule.Name, tc.wantPath, tc.wantKind, tc.wantName)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	rules, err := LoadFile("testdata/ignore.yaml")
+	if err != nil {
+		t.Fatalf("LoadFile() failed: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("LoadFile() returned %d rules, want 2", len(rules))
+	}
+	if rules[1].Kind != "Deployment" || rules[1].Name != "web" {
+		t.Errorf("LoadFile() rule scope = %q/%q, want Deployment/web", rules[1].Kind, rules[1].Name)
+	}
+
+	if _, err := LoadFile("testdata/missing.yaml"); err == nil {
+		t.Error("LoadFile() expected an error for a missing file")
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules, err := LoadFile("testdata/ignore.yaml")
+	if err != nil {
+		t.Fatalf("LoadFile() failed: %v", err)
+	}
+
+	target, local, suppressed, err := Apply(targetRender, localRender, rules)
+	if err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+
+	// Two chart labels and one checksum annotation differ
+	if suppressed != 3 {
+		t.Errorf("Apply() suppressed = %d, want 3", suppressed)
+	}
+
+	for name, render := range map[string]string{"target": target, "local": local} {
+		if strings.Contains(render, "helm.sh/chart") || strings.Contains(render, "checksum/config") {
+			t.Errorf("%s render still contains ignored fields:\n%s", name, render)
+		}
+		if !strings.Contains(render, "# Source: web/templates/deployment.yaml") {
+			t.Errorf("%s render lost the source comment:\n%s", name, render)
+		}
+	}
+
+	// Fields that are not ignored must still differ
+	if !strings.Contains(target, "replicas: 1") || !strings.Contains(local, "replicas: 2") {
+		t.Errorf("Apply() removed a field that is not ignored")
+	}
+}
+
+func TestApplyScope(t *testing.T) {
+	rule, err := ParseRule("ConfigMap:/metadata/labels/helm.sh~1chart")
+	if err != nil {
+		t.Fatalf("ParseRule() failed: %v", err)
+	}
+
+	_, local, suppressed, err := Apply(targetRender, localRender, []Rule{rule})
+	if err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if suppressed != 1 {
+		t.Errorf("Apply() suppressed = %d, want 1", suppressed)
+	}
+	if strings.Count(local, "helm.sh/chart") != 1 {
+		t.Errorf("Apply() should only remove the ConfigMap label:\n%s", local)
+	}
+}
+
+func TestApplyWithoutRules(t *testing.T) {
+	target, local, suppressed, err := Apply(targetRender, localRender, nil)
+	if err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if target != targetRender || local != localRender || suppressed != 0 {
+		t.Error("Apply() without rules should return the renders unchanged")
+	}
+}
+
+func TestApplyOneSided(t *testing.T) {
+	target := `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:1.0.0
+`
+	local := `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    checksum/config: abc
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:1.0.0
+`
+
+	rule, err := ParseRule("/metadata/annotations")
+	if err != nil {
+		t.Fatalf("ParseRule() failed: %v", err)
+	}
+
+	target, local, suppressed, err := Apply(target, local, []Rule{rule})
+	if err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if suppressed != 1 {
+		t.Errorf("Apply() suppressed = %d, want 1", suppressed)
+	}
+
+	// Both sides must share one format, so the diff is empty
+	if got := diff.CreateDiff(target, local, "target", "local"); got != "" {
+		t.Errorf("CreateDiff() after Apply() = %q, want no diff", got)
+	}
+}
@@ -0,0 +1,266 @@
+// Package images extracts the container images of workloads from two
+// renders and reports how they changed, flagging changes that deserve a
+// closer look such as registry changes and downgrades
+package images
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Flag marks an image change that deserves a closer look
+type Flag string
+
+const (
+	// FlagTagToDigest marks an image that was pinned to a digest
+	FlagTagToDigest Flag = "tag-to-digest"
+	// FlagDigestToTag marks an image that is no longer pinned to a digest
+	FlagDigestToTag Flag = "digest-to-tag"
+	// FlagRegistry marks an image pulled from a different registry
+	FlagRegistry Flag = "registry-change"
+	// FlagDowngrade marks a tag that is a lower version than before
+	FlagDowngrade Flag = "downgrade"
+)
+
+// Change is a container whose image was changed, added or removed
+type Change struct {
+	// ResourceID identifies the workload
+	manifest.ResourceID
+	Container string `json:"container"`
+	// Init is set for init containers
+	Init bool `json:"init,omitempty"`
+	// Old is empty when the container was added
+	Old string `json:"old,omitempty"`
+	// New is empty when the container was removed
+	New   string `json:"new,omitempty"`
+	Flags []Flag `json:"flags,omitempty"`
+}
+
+// ContainerName returns the container name, marking init containers
+func (c Change) ContainerName() string {
+	if c.Init {
+		return c.Container + " (init)"
+	}
+	return c.Container
+}
+
+// container is a container of a workload in one render
+type container struct {
+	id    manifest.ResourceID
+	name  string
+	init  bool
+	image string
+}
+
+// key identifies a container across renders
+func (c container) key() string {
+	return fmt.Sprintf("%s\x00%t\x00%s", c.id, c.init, c.name)
+}
+
+// Compare returns the image changes between two renders, ordered as the
+// containers appear in the local render, followed by removed containers
+func Compare(target, local string) ([]Change, error) {
+	targetContainers, err := extract(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target render: %w", err)
+	}
+	localContainers, err := extract(local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse local render: %w", err)
+	}
+
+	before := make(map[string]string, len(targetContainers))
+	for _, c := range targetContainers {
+		before[c.key()] = c.image
+	}
+	after := make(map[string]bool, len(localContainers))
+
+	var changes []Change
+	for _, c := range localContainers {
+		after[c.key()] = true
+		old, existed := before[c.key()]
+		if existed && old == c.image {
+			continue
+		}
+		change := Change{ResourceID: c.id, Container: c.name, Init: c.init, Old: old, New: c.image}
+		if existed {
+			change.Flags = flags(old, c.image)
+		}
+		changes = append(changes, change)
+	}
+	for _, c := range targetContainers {
+		if !after[c.key()] {
+			changes = append(changes, Change{ResourceID: c.id, Container: c.name, Init: c.init, Old: c.image})
+		}
+	}
+
+	return changes, nil
+}
+
+// extract returns the containers and init containers of every workload
+func extract(render string) ([]container, error) {
+	docs, err := manifest.Parse(render)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []container
+	for _, doc := range docs {
+		spec := manifest.PodSpec(doc)
+		if spec == nil {
+			continue
+		}
+
+		for _, list := range []struct {
+			key  string
+			init bool
+		}{{"initContainers", true}, {"containers", false}} {
+			entries := manifest.Lookup(spec, list.key)
+			if entries == nil || entries.Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, entry := range entries.Content {
+				containers = append(containers, container{
+					id:    doc.ID,
+					name:  scalar(entry, "name"),
+					init:  list.init,
+					image: scalar(entry, "image"),
+				})
+			}
+		}
+	}
+
+	return containers, nil
+}
+
+// scalar returns the value of a scalar field in a mapping node
+func scalar(node *yaml.Node, key string) string {
+	if value := manifest.Lookup(node, key); value != nil && value.Kind == yaml.ScalarNode {
+		return value.Value
+	}
+	return ""
+}
+
+// Reference is a parsed image reference
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits an image reference such as
+// "us-docker.pkg.dev/project/repo/app:1.2.3@sha256:..." into its parts.
+// Images without a registry are pulled from Docker Hub.
+func ParseReference(image string) Reference {
+	var ref Reference
+	image, ref.Digest, _ = strings.Cut(image, "@")
+
+	// The tag follows the last colon, unless that colon belongs to the
+	// registry's port, i.e. it comes before the last slash
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, ref.Tag = image[:i], image[i+1:]
+	}
+
+	ref.Registry = "docker.io"
+	if first, rest, found := strings.Cut(image, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, image = first, rest
+	}
+	ref.Repository = image
+	return ref
+}
+
+// flags returns the flags for an image changed from oldImage to newImage
+func flags(oldImage, newImage string) []Flag {
+	before, after := ParseReference(oldImage), ParseReference(newImage)
+
+	var result []Flag
+	switch {
+	case before.Digest == "" && after.Digest != "":
+		result = append(result, FlagTagToDigest)
+	case before.Digest != "" && after.Digest == "":
+		result = append(result, FlagDigestToTag)
+	}
+	if before.Registry != after.Registry {
+		result = append(result, FlagRegistry)
+	}
+	if isDowngrade(before.Tag, after.Tag) {
+		result = append(result, FlagDowngrade)
+	}
+	return result
+}
+
+// versionPattern matches tags that are semantic versions with one to
+// three numeric parts without leading zeros, e.g. "v1.31.0" or "1.27".
+// Date and build tags like "2026.10.01" or "20261001-abc" do not match.
+var versionPattern = regexp.MustCompile(`^v?(0|[1-9][0-9]*)(\.(0|[1-9][0-9]*)){0,2}(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// versionParts returns the number of numeric parts of a version tag
+func versionParts(tag string) int {
+	core, _, _ := strings.Cut(tag, "+")
+	core, _, _ = strings.Cut(core, "-")
+	return strings.Count(core, ".") + 1
+}
+
+// isDowngrade reports whether both tags are versions with the same number
+// of parts and newTag is lower. Other tag changes are not compared.
+func isDowngrade(oldTag, newTag string) bool {
+	if !versionPattern.MatchString(oldTag) || !versionPattern.MatchString(newTag) {
+		return false
+	}
+	if versionParts(oldTag) != versionParts(newTag) {
+		return false
+	}
+	oldVersion, err := semver.NewVersion(oldTag)
+	if err != nil {
+		return false
+	}
+	newVersion, err := semver.NewVersion(newTag)
+	if err != nil {
+		return false
+	}
+	return newVersion.LessThan(oldVersion)
+}
+
+// Write writes the image changes as a table to w. Nothing is written
+// when there are none.
+func Write(w io.Writer, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintln(w, "\nImage changes:"); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "  WORKLOAD\tCONTAINER\tOLD\tNEW\tFLAGS")
+	for _, c := range changes {
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", c.ResourceID, c.ContainerName(), orNone(c.Old), orNone(c.New), JoinFlags(c.Flags))
+	}
+	return tw.Flush()
+}
+
+// JoinFlags joins flags with commas
+func JoinFlags(flags []Flag) string {
+	names := make([]string, len(flags))
+	for i, flag := range flags {
+		names[i] = string(flag)
+	}
+	return strings.Join(names, ", ")
+}
+
+// orNone returns s, or "-" when it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
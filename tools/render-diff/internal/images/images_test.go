@@ -0,0 +1,182 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: app:1.2.0
+      containers:
+        - name: app
+          image: app:1.2.0
+        - name: proxy
+          image: envoyproxy/envoy:v1.31.0
+`
+
+const cronJob = `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: cleanup
+              image: us-docker.pkg.dev/project/repo/cleanup:2024.10.01
+`
+
+func TestCompare(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		local  string
+		want   []string
+	}{
+		{
+			name:   "No changes",
+			target: deployment + "---\n" + cronJob,
+			local:  deployment + "---\n" + cronJob,
+		},
+		{
+			name:   "Image bump",
+			target: deployment,
+			local:  strings.ReplaceAll(deployment, "app:1.2.0", "app:1.3.0"),
+			want: []string{
+				"apps/v1/Deployment/web migrate (init) app:1.2.0 -> app:1.3.0 []",
+				"apps/v1/Deployment/web app app:1.2.0 -> app:1.3.0 []",
+			},
+		},
+		{
+			name:   "Downgrade",
+			target: deployment,
+			local:  strings.Replace(deployment, "envoy:v1.31.0", "envoy:v1.30.2", 1),
+			want:   []string{"apps/v1/Deployment/web proxy envoyproxy/envoy:v1.31.0 -> envoyproxy/envoy:v1.30.2 [downgrade]"},
+		},
+		{
+			name:   "Pinned to a digest",
+			target: deployment,
+			local:  strings.Replace(deployment, "envoy:v1.31.0", "envoy:v1.31.0@sha256:abc", 1),
+			want:   []string{"apps/v1/Deployment/web proxy envoyproxy/envoy:v1.31.0 -> envoyproxy/envoy:v1.31.0@sha256:abc [tag-to-digest]"},
+		},
+		{
+			name:   "Registry change in a CronJob",
+			target: cronJob,
+			local:  strings.Replace(cronJob, "us-docker.pkg.dev/project/repo/cleanup:2024.10.01", "cleanup:2024.09.01", 1),
+			want:   []string{"batch/v1/CronJob/cleanup cleanup us-docker.pkg.dev/project/repo/cleanup:2024.10.01 -> cleanup:2024.09.01 [registry-change]"},
+		},
+		{
+			name:   "Added and removed workloads",
+			target: cronJob,
+			local:  strings.Replace(deployment, "        - name: proxy\n          image: envoyproxy/envoy:v1.31.0\n", "", 1),
+			want: []string{
+				"apps/v1/Deployment/web migrate (init)  -> app:1.2.0 []",
+				"apps/v1/Deployment/web app  -> app:1.2.0 []",
+				"batch/v1/CronJob/cleanup cleanup us-docker.pkg.dev/project/repo/cleanup:2024.10.01 ->  []",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := Compare(tc.target, tc.local)
+			if err != nil {
+				t.Fatalf("Compare() failed: %v", err)
+			}
+
+			var got []string
+			for _, c := range changes {
+				got = append(got, fmt.Sprintf("%s %s %s -> %s %v", c.ResourceID, c.ContainerName(), c.Old, c.New, c.Flags))
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Compare() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		if _, err := Compare("a: [b\n", ""); err == nil {
+			t.Error("Compare() expected an error for invalid YAML")
+		}
+	})
+}
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		image string
+		want  Reference
+	}{
+		{image: "nginx", want: Reference{Registry: "docker.io", Repository: "nginx"}},
+		{image: "nginx:1.27", want: Reference{Registry: "docker.io", Repository: "nginx", Tag: "1.27"}},
+		{image: "envoyproxy/envoy:v1.31.0", want: Reference{Registry: "docker.io", Repository: "envoyproxy/envoy", Tag: "v1.31.0"}},
+		{image: "localhost:5000/app", want: Reference{Registry: "localhost:5000", Repository: "app"}},
+		{image: "us-docker.pkg.dev/p/r/app:1.0@sha256:abc", want: Reference{Registry: "us-docker.pkg.dev", Repository: "p/r/app", Tag: "1.0", Digest: "sha256:abc"}},
+		{image: "ghcr.io/org/app@sha256:abc", want: Reference{Registry: "ghcr.io", Repository: "org/app", Digest: "sha256:abc"}},
+	}
+
+	for _, tc := range testCases {
+		if got := ParseReference(tc.image); got != tc.want {
+			t.Errorf("ParseReference(%q) = %+v, want %+v", tc.image, got, tc.want)
+		}
+	}
+}
+
+func TestIsDowngrade(t *testing.T) {
+	testCases := []struct {
+		oldTag string
+		newTag string
+		want   bool
+	}{
+		{oldTag: "v1.31.0", newTag: "v1.30.2", want: true},
+		{oldTag: "1.27", newTag: "1.26", want: true},
+		{oldTag: "1.2.0", newTag: "1.2.0-rc.1", want: true},
+		{oldTag: "1.2.0", newTag: "1.3.0", want: false},
+		{oldTag: "2026.10.01", newTag: "20261001-abc", want: false},
+		{oldTag: "2026.10.01", newTag: "2026.09.01", want: false},
+		{oldTag: "1.27", newTag: "1.26.9", want: false},
+		{oldTag: "latest", newTag: "1.0.0", want: false},
+		{oldTag: "", newTag: "1.0.0", want: false},
+	}
+
+	for _, tc := range testCases {
+		if got := isDowngrade(tc.oldTag, tc.newTag); got != tc.want {
+			t.Errorf("isDowngrade(%q, %q) = %v, want %v", tc.oldTag, tc.newTag, got, tc.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, nil); err != nil || b.Len() != 0 {
+		t.Errorf("Write() with no changes wrote %q, %v", b.String(), err)
+	}
+
+	changes, err := Compare(deployment, strings.Replace(deployment, "envoy:v1.31.0", "envoy:v1.30.2", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&b, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+Image changes:
+  WORKLOAD                CONTAINER  OLD                       NEW                       FLAGS
+  apps/v1/Deployment/web  proxy      envoyproxy/envoy:v1.31.0  envoyproxy/envoy:v1.30.2  downgrade
+`
+	if b.String() != want {
+		t.Errorf("Write() =\n%s\nwant:\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,187 @@
+// Package manifest provides helpers for splitting rendered manifests
+// into documents and identifying the Kubernetes objects they contain
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ResourceID identifies a Kubernetes object by group, version, kind,
+// namespace and name
+type ResourceID struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// APIVersion returns the apiVersion of the object, e.g. "apps/v1" or "v1"
+func (id ResourceID) APIVersion() string {
+	if id.Group == "" {
+		return id.Version
+	}
+	return id.Group + "/" + id.Version
+}
+
+// String returns the identifier in the same format dyff uses to match
+// documents: apiVersion/kind[/namespace]/name
+func (id ResourceID) String() string {
+	elem := []string{id.APIVersion(), id.Kind}
+	if id.Namespace != "" {
+		elem = append(elem, id.Namespace)
+	}
+	elem = append(elem, id.Name)
+	return strings.Join(elem, "/")
+}
+
+// Document is a single YAML document from a rendered manifest stream
+type Document struct {
+	ID ResourceID
+	// Raw is the original text of the document, including leading
+	// comments such as Helm's "# Source:" line, without the separator
+	Raw string
+	// Node is the parsed document node
+	Node *yaml.Node
+}
+
+// Parse splits a multi-document YAML stream into its documents. Documents
+// that only contain whitespace or comments are skipped.
+func Parse(content string) ([]*Document, error) {
+	var docs []*Document
+
+	for i, raw := range split(content) {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(raw), &node); err != nil {
+			return nil, fmt.Errorf("failed to decode YAML document %d: %w", i+1, err)
+		}
+		if isEmpty(&node) {
+			continue
+		}
+		docs = append(docs, &Document{
+			ID:   IDFromNode(&node),
+			Raw:  raw,
+			Node: &node,
+		})
+	}
+
+	return docs, nil
+}
+
+// Update re-encodes Raw from Node after the node was modified.
+// Comments, quoting and block styles are preserved by the encoder.
+func (d *Document) Update() error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(d.Node); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", d.ID, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", d.ID, err)
+	}
+
+	d.Raw = buf.String()
+	return nil
+}
+
+// Join writes documents back into a single YAML stream
+func Join(docs []*Document) string {
+	var b strings.Builder
+	for _, doc := range docs {
+		b.WriteString("---\n")
+		b.WriteString(doc.Raw)
+		if !strings.HasSuffix(doc.Raw, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// isEmpty reports whether a parsed document has no content
+func isEmpty(node *yaml.Node) bool {
+	if node.Kind == 0 || len(node.Content) == 0 {
+		return true
+	}
+	content := node.Content[0]
+	return content.Kind == yaml.ScalarNode && content.Tag == "!!null"
+}
+
+// split cuts a YAML stream on "---" separator lines
+func split(content string) []string {
+	var chunks []string
+	var current strings.Builder
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.TrimRight(line, " \t\r\n") == "---" {
+			if strings.TrimSpace(current.String()) != "" {
+				chunks = append(chunks, current.String())
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteString(line)
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		chunks = append(chunks, current.String())
+	}
+
+	return chunks
+}
+
+// Index maps each document by its ResourceID string
+func Index(docs []*Document) map[string]*Document {
+	index := make(map[string]*Document, len(docs))
+	for _, doc := range docs {
+		index[doc.ID.String()] = doc
+	}
+	return index
+}
+
+// IDFromNode reads the identifying fields from a document or mapping node.
+// Missing fields are left empty.
+func IDFromNode(node *yaml.Node) ResourceID {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	var id ResourceID
+	group, version, found := strings.Cut(scalar(node, "apiVersion"), "/")
+	if found {
+		id.Group, id.Version = group, version
+	} else {
+		id.Version = group
+	}
+	id.Kind = scalar(node, "kind")
+
+	metadata := Lookup(node, "metadata")
+	id.Namespace = scalar(metadata, "namespace")
+	id.Name = scalar(metadata, "name")
+
+	return id
+}
+
+// Lookup returns the value node for key in a mapping node, or nil
+func Lookup(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalar returns the value of a scalar field in a mapping node
+func scalar(node *yaml.Node, key string) string {
+	if value := Lookup(node, key); value != nil && value.Kind == yaml.ScalarNode {
+		return value.Value
+	}
+	return ""
+}
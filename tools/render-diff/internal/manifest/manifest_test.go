@@ -0,0 +1,100 @@
+package manifest
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestIDFromNode(t *testing.T) {
+	testCases := []struct {
+		name       string
+		doc        string
+		want       ResourceID
+		wantString string
+	}{
+		{
+			name:       "Grouped namespaced object",
+			doc:        "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: prod\n",
+			want:       ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "prod", Name: "web"},
+			wantString: "apps/v1/Deployment/prod/web",
+		},
+		{
+			name:       "Core object without namespace",
+			doc:        "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n",
+			want:       ResourceID{Version: "v1", Kind: "ConfigMap", Name: "config"},
+			wantString: "v1/ConfigMap/config",
+		},
+		{
+			name:       "Missing metadata",
+			doc:        "apiVersion: v1\nkind: List\n",
+			want:       ResourceID{Version: "v1", Kind: "List"},
+			wantString: "v1/List/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(tc.doc), &node); err != nil {
+				t.Fatalf("failed to parse test document: %v", err)
+			}
+
+			got := IDFromNode(&node)
+			if got != tc.want {
+				t.Errorf("IDFromNode() = %+v, want %+v", got, tc.want)
+			}
+			if got.String() != tc.wantString {
+				t.Errorf("String() = %q, want %q", got.String(), tc.wantString)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	content := `---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  script: |
+    echo one
+    echo two
+---
+# Source: chart/templates/empty.yaml
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
+
+	docs, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	if len(docs) != 2 {
+		t.Fatalf("Parse() returned %d documents, want 2", len(docs))
+	}
+
+	if got := docs[0].ID.String(); got != "v1/ConfigMap/config" {
+		t.Errorf("docs[0].ID = %q, want %q", got, "v1/ConfigMap/config")
+	}
+
+	wantRaw := "# Source: chart/templates/configmap.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  script: |\n    echo one\n    echo two\n"
+	if docs[0].Raw != wantRaw {
+		t.Errorf("docs[0].Raw = %q, want %q", docs[0].Raw, wantRaw)
+	}
+
+	index := Index(docs)
+	if _, ok := index["apps/v1/Deployment/web"]; !ok {
+		t.Errorf("Index() missing Deployment, got keys %v", index)
+	}
+
+	if _, err := Parse("key: [unclosed"); err == nil {
+		t.Error("Parse() succeeded on invalid YAML, expected an error")
+	}
+}
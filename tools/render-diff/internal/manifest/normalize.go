@@ -0,0 +1,69 @@
+package manifest
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Normalize rewrites a rendered manifest into a canonical form, so that a
+// line diff only shows changes to the objects themselves. Documents are
+// sorted by group, kind, version, namespace and name, mapping keys are
+// sorted, and comments such as Helm's "# Source:" lines are dropped.
+func Normalize(render string) (string, error) {
+	docs, err := Parse(render)
+	if err != nil {
+		return "", err
+	}
+
+	for _, doc := range docs {
+		canonicalize(doc.Node)
+		if err := doc.Update(); err != nil {
+			return "", err
+		}
+	}
+
+	// Objects without an identity, or defined twice, are ordered by
+	// content so the result does not depend on the input order
+	sort.SliceStable(docs, func(i, j int) bool {
+		a, b := docs[i].ID, docs[j].ID
+		for _, pair := range [][2]string{
+			{a.Group, b.Group},
+			{a.Kind, b.Kind},
+			{a.Version, b.Version},
+			{a.Namespace, b.Namespace},
+			{a.Name, b.Name},
+		} {
+			if pair[0] != pair[1] {
+				return pair[0] < pair[1]
+			}
+		}
+		return docs[i].Raw < docs[j].Raw
+	})
+
+	return Join(docs), nil
+}
+
+// canonicalize sorts the keys of every mapping below node and removes
+// all comments
+func canonicalize(node *yaml.Node) {
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	for _, child := range node.Content {
+		canonicalize(child)
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i][0].Value < pairs[j][0].Value
+	})
+	for i, pair := range pairs {
+		node.Content[2*i], node.Content[2*i+1] = pair[0], pair[1]
+	}
+}
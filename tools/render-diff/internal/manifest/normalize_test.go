@@ -0,0 +1,105 @@
+package manifest
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	const service = `# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80 # http
+      name: http
+`
+	const deployment = `# Source: web/templates/deployment.yaml
+kind: Deployment
+apiVersion: apps/v1
+metadata:
+  namespace: web
+  name: web
+  labels:
+    tier: frontend
+    app: web
+`
+	const configMap = `# Source: web/templates/all.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  b: "2"
+  a: "1"
+`
+
+	const want = `---
+apiVersion: v1
+data:
+  a: "1"
+  b: "2"
+kind: ConfigMap
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - name: http
+      port: 80
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+    tier: frontend
+  name: web
+  namespace: web
+`
+
+	testCases := []struct {
+		name   string
+		render string
+		want   string
+	}{
+		{
+			name:   "Template order",
+			render: "---\n" + service + "---\n" + deployment + "---\n" + configMap,
+			want:   want,
+		},
+		{
+			name:   "Resources moved between templates",
+			render: "---\n" + configMap + "---\n" + deployment + "---\n" + service,
+			want:   want,
+		},
+		{
+			name:   "Empty render",
+			render: "",
+			want:   "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Normalize(tc.render)
+			if err != nil {
+				t.Fatalf("Normalize() failed: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Normalize() =\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		if _, err := Normalize("a: [b\n"); err == nil {
+			t.Error("Normalize() expected an error for invalid YAML")
+		}
+	})
+}
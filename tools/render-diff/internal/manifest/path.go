@@ -0,0 +1,229 @@
+package manifest
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Path selects fields in a document. It can be written in go-patch style
+// ("/metadata/labels/helm.sh~1chart", "/spec/containers/name=app/image")
+// or in JSON path style ("$.metadata.labels['helm.sh/chart']",
+// "spec.containers[0].image"). Every segment may be a glob pattern, and
+// "*" matches every key of a map or every entry of a list.
+type Path []string
+
+// Match is a field selected by a Path
+type Match struct {
+	// Path is the concrete go-patch style path of the field
+	Path string
+	Node *yaml.Node
+}
+
+// ParsePath parses a go-patch or JSON path style path
+func ParsePath(s string) (Path, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("path is empty")
+	}
+
+	var segments []string
+	var err error
+	if strings.HasPrefix(s, "/") {
+		segments = parseGoPatch(s)
+	} else {
+		segments, err = parseJSONPath(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in path %q: %w", segment, s, err)
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("path %q does not select any field", s)
+	}
+
+	return Path(segments), nil
+}
+
+// parseGoPatch splits a go-patch path, unescaping "~1" to "/" and "~0" to "~"
+func parseGoPatch(s string) []string {
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimPrefix(s, "/"), "/") {
+		// go-patch marks optional segments with a trailing "?"
+		segment = strings.TrimSuffix(segment, "?")
+		segment = strings.ReplaceAll(segment, "~1", "/")
+		segment = strings.ReplaceAll(segment, "~0", "~")
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// parseJSONPath splits a JSON path such as $.a.b['c.d'][0][name=app]
+func parseJSONPath(s string) ([]string, error) {
+	s = strings.TrimPrefix(s, "$")
+
+	var segments []string
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+		case '[':
+			end, segment, err := parseBracket(s)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+			s = s[end:]
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			segments = append(segments, s[:end])
+			s = s[end:]
+		}
+	}
+
+	return segments, nil
+}
+
+// parseBracket parses a bracketed segment at the start of s and
+// returns the index just past the closing bracket
+func parseBracket(s string) (int, string, error) {
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		quote := s[1]
+		end := strings.IndexByte(s[2:], quote)
+		if end < 0 || len(s) < end+4 || s[end+3] != ']' {
+			return 0, "", fmt.Errorf("unterminated quoted key in path segment %q", s)
+		}
+		return end + 4, s[2 : end+2], nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return 0, "", fmt.Errorf("unterminated bracket in path segment %q", s)
+	}
+	return end + 1, s[1:end], nil
+}
+
+// String returns the path in go-patch style
+func (p Path) String() string {
+	return "/" + strings.Join(escapeAll(p), "/")
+}
+
+// Find returns every field selected by the path in a document or mapping node
+func (p Path) Find(root *yaml.Node) []Match {
+	if root != nil && root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+
+	var matches []Match
+	var walk func(node *yaml.Node, segments []string, concrete []string)
+	walk = func(node *yaml.Node, segments []string, concrete []string) {
+		if node == nil {
+			return
+		}
+		if len(segments) == 0 {
+			matches = append(matches, Match{Path: "/" + strings.Join(escapeAll(concrete), "/"), Node: node})
+			return
+		}
+
+		segment, rest := segments[0], segments[1:]
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if ok, _ := path.Match(segment, key); ok {
+					walk(node.Content[i+1], rest, append(concrete, key))
+				}
+			}
+		case yaml.SequenceNode:
+			for i, entry := range node.Content {
+				if element, ok := matchListEntry(segment, i, entry); ok {
+					walk(entry, rest, append(concrete, element))
+				}
+			}
+		case yaml.AliasNode:
+			walk(node.Alias, segments, concrete)
+		}
+	}
+
+	walk(root, p, nil)
+	return matches
+}
+
+// matchListEntry checks a list entry against a segment, which can be an
+// index, "*" or a "field=value" selector. It returns the concrete segment.
+func matchListEntry(segment string, i int, entry *yaml.Node) (string, bool) {
+	if segment == "*" {
+		return strconv.Itoa(i), true
+	}
+	if idx, err := strconv.Atoi(segment); err == nil {
+		return segment, idx == i
+	}
+
+	field, pattern, found := strings.Cut(segment, "=")
+	if !found {
+		return "", false
+	}
+	value := Lookup(entry, field)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	if ok, _ := path.Match(pattern, value.Value); ok {
+		return field + "=" + value.Value, true
+	}
+	return "", false
+}
+
+// escapeAll escapes "~" and "/" in go-patch path segments
+func escapeAll(segments []string) []string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		segment = strings.ReplaceAll(segment, "~", "~0")
+		escaped[i] = strings.ReplaceAll(segment, "/", "~1")
+	}
+	return escaped
+}
+
+// Remove deletes the given nodes from the tree below root. Map entries
+// are removed together with their key.
+func Remove(root *yaml.Node, nodes map[*yaml.Node]bool) {
+	if root == nil || len(nodes) == 0 {
+		return
+	}
+
+	switch root.Kind {
+	case yaml.MappingNode:
+		content := root.Content[:0]
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if nodes[root.Content[i+1]] {
+				continue
+			}
+			content = append(content, root.Content[i], root.Content[i+1])
+		}
+		root.Content = content
+	case yaml.SequenceNode:
+		content := root.Content[:0]
+		for _, entry := range root.Content {
+			if !nodes[entry] {
+				content = append(content, entry)
+			}
+		}
+		root.Content = content
+	}
+
+	for _, child := range root.Content {
+		Remove(child, nodes)
+	}
+}
@@ -0,0 +1,114 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParsePath(t *testing.T) {
+	testCases := []struct {
+		name    string
+		path    string
+		want    Path
+		wantErr bool
+	}{
+		{name: "go-patch", path: "/metadata/labels/helm.sh~1chart", want: Path{"metadata", "labels", "helm.sh/chart"}},
+		{name: "go-patch selector", path: "/spec/containers/name=app/image", want: Path{"spec", "containers", "name=app", "image"}},
+		{name: "JSON path", path: "$.metadata.labels['helm.sh/chart']", want: Path{"metadata", "labels", "helm.sh/chart"}},
+		{name: "JSON path without root", path: "spec.containers[0].image", want: Path{"spec", "containers", "0", "image"}},
+		{name: "JSON path with selector", path: `.spec.containers[name=app]["image"]`, want: Path{"spec", "containers", "name=app", "image"}},
+		{name: "Empty", path: "", wantErr: true},
+		{name: "Unterminated quote", path: "metadata['a", wantErr: true},
+		{name: "Invalid glob", path: "/metadata/[", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParsePath(tc.path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParsePath(%q) error = %v, wantErr %v", tc.path, err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParsePath(%q) = %#v, want %#v", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPathFind(t *testing.T) {
+	doc := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    helm.sh/chart: web-1.2.3
+  annotations:
+    checksum/config: abc
+    checksum/secret: def
+    other: keep
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: app:1
+        - name: sidecar
+          image: proxy:2
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(doc), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path string
+		want []string
+	}{
+		{path: "/metadata/labels/helm.sh~1chart", want: []string{"/metadata/labels/helm.sh~1chart"}},
+		{path: "/metadata/annotations/checksum~1*", want: []string{"/metadata/annotations/checksum~1config", "/metadata/annotations/checksum~1secret"}},
+		{path: "spec.template.spec.containers[*].image", want: []string{"/spec/template/spec/containers/0/image", "/spec/template/spec/containers/1/image"}},
+		{path: "/spec/template/spec/containers/name=side*/image", want: []string{"/spec/template/spec/containers/name=sidecar/image"}},
+		{path: "/spec/missing", want: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			p, err := ParsePath(tc.path)
+			if err != nil {
+				t.Fatalf("ParsePath() failed: %v", err)
+			}
+
+			var got []string
+			for _, m := range p.Find(&node) {
+				got = append(got, m.Path)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Find() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	docs, err := Parse("# Source: chart/templates/cm.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\n  labels:\n    drop: me\n    keep: me\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := ParsePath("/metadata/labels/drop")
+	remove := make(map[*yaml.Node]bool)
+	for _, m := range p.Find(docs[0].Node) {
+		remove[m.Node] = true
+	}
+	Remove(docs[0].Node, remove)
+
+	if err := docs[0].Update(); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	want := "---\n# Source: chart/templates/cm.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\n  labels:\n    keep: me\n"
+	if got := Join(docs); got != want {
+		t.Errorf("Join() = %q, want %q", got, want)
+	}
+}
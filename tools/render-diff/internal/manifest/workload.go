@@ -0,0 +1,35 @@
+package manifest
+
+import "gopkg.in/yaml.v3"
+
+// podSpecPaths maps each workload kind, by API group, to the path of its
+// pod spec
+var podSpecPaths = map[[2]string][]string{
+	{"apps", "Deployment"}:     {"spec", "template", "spec"},
+	{"apps", "StatefulSet"}:    {"spec", "template", "spec"},
+	{"apps", "DaemonSet"}:      {"spec", "template", "spec"},
+	{"batch", "Job"}:           {"spec", "template", "spec"},
+	{"batch", "CronJob"}:       {"spec", "jobTemplate", "spec", "template", "spec"},
+	{"argoproj.io", "Rollout"}: {"spec", "template", "spec"},
+}
+
+// PodSpec returns the pod spec of a workload document, or nil when the
+// document is not a workload or has no pod template
+func PodSpec(doc *Document) *yaml.Node {
+	segments, ok := podSpecPaths[[2]string{doc.ID.Group, doc.ID.Kind}]
+	if !ok {
+		return nil
+	}
+
+	node := doc.Node
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, segment := range segments {
+		node = Lookup(node, segment)
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	return node
+}
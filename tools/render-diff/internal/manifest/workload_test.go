@@ -0,0 +1,49 @@
+package manifest
+
+import (
+	"testing"
+)
+
+func TestPodSpec(t *testing.T) {
+	testCases := []struct {
+		name     string
+		doc      string
+		wantNode bool
+	}{
+		{
+			name:     "Deployment",
+			doc:      "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers: []\n",
+			wantNode: true,
+		},
+		{
+			name:     "CronJob",
+			doc:      "apiVersion: batch/v1\nkind: CronJob\nmetadata:\n  name: job\nspec:\n  jobTemplate:\n    spec:\n      template:\n        spec:\n          containers: []\n",
+			wantNode: true,
+		},
+		{
+			name: "Rollout referencing a Deployment",
+			doc:  "apiVersion: argoproj.io/v1alpha1\nkind: Rollout\nmetadata:\n  name: web\nspec:\n  workloadRef:\n    kind: Deployment\n    name: web\n",
+		},
+		{
+			name: "Not a workload",
+			doc:  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			docs, err := Parse(tc.doc)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			spec := PodSpec(docs[0])
+			if (spec != nil) != tc.wantNode {
+				t.Fatalf("PodSpec() = %v, want a node: %t", spec, tc.wantNode)
+			}
+			if spec != nil && Lookup(spec, "containers") == nil {
+				t.Errorf("PodSpec() returned a node without containers")
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+// Package policy checks rendered manifests against organization policies.
+// Policies are declarative rules read from YAML files in a directory and
+// evaluated offline against the render and the changes made to it. Rules
+// are a path and conditions on it rather than CEL or Rego expressions, so
+// they need no policy engine and read like ignore rules.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity ranks how serious a violation is
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// severities orders the severities from least to most serious
+var severities = []Severity{SeverityInfo, SeverityWarning, SeverityError}
+
+// ParseSeverity parses a severity name
+func ParseSeverity(s string) (Severity, error) {
+	for _, severity := range severities {
+		if s == string(severity) {
+			return severity, nil
+		}
+	}
+	return "", fmt.Errorf("invalid severity '%s', must be one of: info, warning, error", s)
+}
+
+// AtLeast reports whether s is as serious as min or more
+func (s Severity) AtLeast(min Severity) bool {
+	return rank(s) >= rank(min)
+}
+
+// rank returns the position of a severity in severities
+func rank(s Severity) int {
+	for i, severity := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Match selects the objects a rule applies to. Every field accepts a glob
+// pattern, and empty fields match every object.
+type Match struct {
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// Rule is a single policy. It checks the fields selected by Path in the
+// matched objects with every condition that is set.
+type Rule struct {
+	Name     string   `yaml:"name"`
+	Severity Severity `yaml:"severity"`
+	// Message explains the policy to the author of a change
+	Message string `yaml:"message"`
+	Match   Match  `yaml:"match"`
+	Path    string `yaml:"path"`
+
+	// Required fails when the path selects no field
+	Required bool `yaml:"required"`
+	// NotRemoved fails when a field that existed on the target ref is removed
+	NotRemoved bool `yaml:"notRemoved"`
+	// Equals fails when a selected field has a different value
+	Equals any `yaml:"equals"`
+	// Matches fails when a selected field does not match the regular expression
+	Matches string `yaml:"matches"`
+	// NotMatches fails when a selected field matches the regular expression
+	NotMatches string `yaml:"notMatches"`
+
+	path       manifest.Path
+	matches    *regexp.Regexp
+	notMatches *regexp.Regexp
+}
+
+// File is the layout of a policy file
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Violation is a field of an object that breaks a rule
+type Violation struct {
+	manifest.ResourceID
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path"`
+	Message  string   `json:"message,omitempty"`
+	// Detail describes what was found, e.g. the offending value
+	Detail string `json:"detail"`
+}
+
+// String describes the violation on a single line
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s: %s", v.ResourceID, v.Path, v.Description())
+}
+
+// Description returns the rule's message followed by the detail
+func (v Violation) Description() string {
+	if v.Message == "" {
+		return v.Detail
+	}
+	return fmt.Sprintf("%s (%s)", v.Message, v.Detail)
+}
+
+// key identifies a violation when comparing two renders
+func (v Violation) key() string {
+	return strings.Join([]string{v.Rule, v.ResourceID.String(), v.Path, v.Detail}, "\x00")
+}
+
+// LoadDir reads the rules of every .yaml and .yml file in dir, in file
+// name order. Rule names must be unique across files.
+func LoadDir(dir string) ([]Rule, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy directory: %w", err)
+	}
+
+	var rules []Rule
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		filename := filepath.Join(dir, entry.Name())
+		fileRules, err := loadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range fileRules {
+			if other, ok := seen[rule.Name]; ok {
+				return nil, fmt.Errorf("policy '%s' in %s is already defined in %s", rule.Name, filename, other)
+			}
+			seen[rule.Name] = filename
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no policies found in %s", dir)
+	}
+	return rules, nil
+}
+
+// loadFile reads and compiles the rules in a policy file
+func loadFile(filename string) ([]Rule, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", filename, err)
+	}
+
+	for i := range file.Rules {
+		if err := file.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid policy in %s: %w", filename, err)
+		}
+	}
+	return file.Rules, nil
+}
+
+// compile checks the rule and parses its path, patterns and expressions
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("policy has no name")
+	}
+
+	if r.Severity == "" {
+		r.Severity = SeverityError
+	}
+	if _, err := ParseSeverity(string(r.Severity)); err != nil {
+		return fmt.Errorf("policy '%s': %w", r.Name, err)
+	}
+
+	p, err := manifest.ParsePath(r.Path)
+	if err != nil {
+		return fmt.Errorf("policy '%s': %w", r.Name, err)
+	}
+	r.path = p
+
+	for _, pattern := range []string{r.Match.Kind, r.Match.Name, r.Match.Namespace} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("policy '%s': bad pattern %q: %w", r.Name, pattern, err)
+		}
+	}
+
+	if r.Matches != "" {
+		if r.matches, err = regexp.Compile(r.Matches); err != nil {
+			return fmt.Errorf("policy '%s': invalid matches expression: %w", r.Name, err)
+		}
+	}
+	if r.NotMatches != "" {
+		if r.notMatches, err = regexp.Compile(r.NotMatches); err != nil {
+			return fmt.Errorf("policy '%s': invalid notMatches expression: %w", r.Name, err)
+		}
+	}
+
+	if !r.Required && !r.NotRemoved && r.Equals == nil && r.matches == nil && r.notMatches == nil {
+		return fmt.Errorf("policy '%s' has no condition, set one of required, notRemoved, equals, matches or notMatches", r.Name)
+	}
+	return nil
+}
+
+// Applies reports whether the rule applies to an object
+func (r Rule) Applies(id manifest.ResourceID) bool {
+	for _, pair := range [][2]string{
+		{r.Match.Kind, id.Kind},
+		{r.Match.Name, id.Name},
+		{r.Match.Namespace, id.Namespace},
+	} {
+		if pair[0] == "" {
+			continue
+		}
+		if ok, _ := path.Match(pair[0], pair[1]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate checks the local render against the rules and returns the
+// violations that do not already exist in the target render, ordered as
+// the objects appear in the local render
+func Evaluate(rules []Rule, target, local string) ([]Violation, error) {
+	targetDocs, err := manifest.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target render: %w", err)
+	}
+	localDocs, err := manifest.Parse(local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse local render: %w", err)
+	}
+
+	known := make(map[string]bool)
+	for _, doc := range targetDocs {
+		violations, err := check(rules, doc, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range violations {
+			known[v.key()] = true
+		}
+	}
+
+	targetIndex := manifest.Index(targetDocs)
+
+	var result []Violation
+	for _, doc := range localDocs {
+		violations, err := check(rules, doc, targetIndex[doc.ID.String()])
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range violations {
+			if !known[v.key()] {
+				result = append(result, v)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// check evaluates the rules against one object. previous is the object on
+// the target ref, or nil when it is new.
+func check(rules []Rule, doc, previous *manifest.Document) ([]Violation, error) {
+	var violations []Violation
+	for _, rule := range rules {
+		if !rule.Applies(doc.ID) {
+			continue
+		}
+
+		violation := func(path, detail string) {
+			violations = append(violations, Violation{
+				ResourceID: doc.ID,
+				Rule:       rule.Name,
+				Severity:   rule.Severity,
+				Path:       path,
+				Message:    rule.Message,
+				Detail:     detail,
+			})
+		}
+
+		matches := rule.path.Find(doc.Node)
+		if rule.Required && len(matches) == 0 {
+			violation(rule.path.String(), "field is missing")
+		}
+
+		if rule.NotRemoved && previous != nil {
+			present := make(map[string]bool, len(matches))
+			for _, m := range matches {
+				present[m.Path] = true
+			}
+			for _, m := range rule.path.Find(previous.Node) {
+				if !present[m.Path] {
+					violation(m.Path, "field was removed")
+				}
+			}
+		}
+
+		for _, m := range matches {
+			detail, err := rule.checkValue(m.Node)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check policy '%s' on %s %s: %w", rule.Name, doc.ID, m.Path, err)
+			}
+			if detail != "" {
+				violation(m.Path, detail)
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// checkValue runs the value conditions of the rule on a field. It returns
+// a description of the first failed condition, or an empty string.
+func (r Rule) checkValue(node *yaml.Node) (string, error) {
+	if r.Equals != nil {
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return "", err
+		}
+		if !reflect.DeepEqual(value, r.Equals) {
+			return fmt.Sprintf("is %s, must be %s", format(value), format(r.Equals)), nil
+		}
+	}
+
+	if r.matches == nil && r.notMatches == nil {
+		return "", nil
+	}
+	if node.Kind != yaml.ScalarNode {
+		return "is not a single value", nil
+	}
+	if r.matches != nil && !r.matches.MatchString(node.Value) {
+		return fmt.Sprintf("%q does not match %q", node.Value, r.Matches), nil
+	}
+	if r.notMatches != nil && r.notMatches.MatchString(node.Value) {
+		return fmt.Sprintf("%q matches %q", node.Value, r.NotMatches), nil
+	}
+	return "", nil
+}
+
+// format writes a decoded value as compact JSON for messages
+func format(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// Failed reports whether any violation is at least as serious as min
+func Failed(violations []Violation, min Severity) bool {
+	for _, v := range violations {
+		if v.Severity.AtLeast(min) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes a human readable list of violations to w, most serious first
+func Write(w io.Writer, violations []Violation) error {
+	if len(violations) == 0 {
+		_, err := fmt.Fprintln(w, "\nPolicy checks: no new violations.")
+		return err
+	}
+
+	violationStr := "violation"
+	if len(violations) != 1 {
+		violationStr = "violations"
+	}
+	if _, err := fmt.Fprintf(w, "\nPolicy checks: %d new %s:\n", len(violations), violationStr); err != nil {
+		return err
+	}
+
+	for _, v := range Sorted(violations) {
+		if _, err := fmt.Fprintf(w, "  - [%s] %s: %s\n", v.Severity, v.Rule, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sorted returns a copy of the violations ordered by severity, most
+// serious first, keeping the object order within a severity
+func Sorted(violations []Violation) []Violation {
+	sorted := append([]Violation{}, violations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rank(sorted[i].Severity) > rank(sorted[j].Severity)
+	})
+	return sorted
+}
@@ -0,0 +1,255 @@
+package policy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const policyDir = "testdata/policies"
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      securityContext:
+        runAsNonRoot: true
+      containers:
+        - name: app
+          image: nginx:1.27
+          resources:
+            limits:
+              memory: 256Mi
+`
+
+const ingress = `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+    - host: web.mozilla.org
+`
+
+func TestLoadDir(t *testing.T) {
+	rules, err := LoadDir(policyDir)
+	if err != nil {
+		t.Fatalf("LoadDir() failed: %v", err)
+	}
+
+	var names []string
+	for _, rule := range rules {
+		names = append(names, rule.Name+"="+string(rule.Severity))
+	}
+	want := "no-latest-tag=error run-as-non-root=error keep-limits=warning allowed-hosts=info"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("LoadDir() = %s, want %s", got, want)
+	}
+}
+
+func TestLoadDirErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "No rules",
+			content: "",
+			wantErr: "no policies found",
+		},
+		{
+			name:    "Unknown field",
+			content: "rules:\n  - name: a\n    path: /spec\n    required: true\n    regex: x\n",
+			wantErr: "field regex not found",
+		},
+		{
+			name:    "Missing name",
+			content: "rules:\n  - path: /spec\n    required: true\n",
+			wantErr: "policy has no name",
+		},
+		{
+			name:    "Invalid severity",
+			content: "rules:\n  - name: a\n    severity: fatal\n    path: /spec\n    required: true\n",
+			wantErr: "invalid severity 'fatal'",
+		},
+		{
+			name:    "Missing path",
+			content: "rules:\n  - name: a\n    required: true\n",
+			wantErr: "path is empty",
+		},
+		{
+			name:    "Invalid expression",
+			content: "rules:\n  - name: a\n    path: /spec\n    matches: '('\n",
+			wantErr: "invalid matches expression",
+		},
+		{
+			name:    "No condition",
+			content: "rules:\n  - name: a\n    path: /spec\n",
+			wantErr: "has no condition",
+		},
+		{
+			name:    "Duplicate name",
+			content: "rules:\n  - name: a\n    path: /spec\n    required: true\n  - name: a\n    path: /spec\n    required: true\n",
+			wantErr: "policy 'a'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "policy.yaml"), []byte(tc.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadDir(dir)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("LoadDir() error = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	t.Run("Missing directory", func(t *testing.T) {
+		if _, err := LoadDir("testdata/does-not-exist"); err == nil {
+			t.Error("LoadDir() expected an error for a missing directory")
+		}
+	})
+}
+
+func TestEvaluate(t *testing.T) {
+	rules, err := LoadDir(policyDir)
+	if err != nil {
+		t.Fatalf("LoadDir() failed: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		target string
+		local  string
+		want   []string
+	}{
+		{
+			name:   "Compliant change",
+			target: deployment,
+			local:  strings.Replace(deployment, "nginx:1.27", "nginx:1.28", 1),
+		},
+		{
+			name:   "Latest tag",
+			target: deployment,
+			local:  strings.Replace(deployment, "nginx:1.27", "nginx:latest", 1),
+			want:   []string{"no-latest-tag /spec/template/spec/containers/name=app/image"},
+		},
+		{
+			name:   "Untagged image in a new object",
+			target: "",
+			local:  strings.Replace(deployment, "nginx:1.27", "nginx", 1),
+			want:   []string{"no-latest-tag /spec/template/spec/containers/name=app/image"},
+		},
+		{
+			name:   "Existing violation",
+			target: strings.Replace(deployment, "nginx:1.27", "nginx:latest", 1),
+			local:  strings.Replace(strings.Replace(deployment, "nginx:1.27", "nginx:latest", 1), "256Mi", "512Mi", 1),
+		},
+		{
+			name:   "Non-root disabled",
+			target: deployment,
+			local:  strings.Replace(deployment, "runAsNonRoot: true", "runAsNonRoot: false", 1),
+			want:   []string{"run-as-non-root /spec/template/spec/securityContext/runAsNonRoot"},
+		},
+		{
+			name:   "Non-root and limits removed",
+			target: deployment,
+			local:  strings.Replace(strings.Replace(deployment, "        runAsNonRoot: true\n", "        fsGroup: 1000\n", 1), "          resources:\n            limits:\n              memory: 256Mi\n", "", 1),
+			want: []string{
+				"run-as-non-root /spec/template/spec/securityContext/runAsNonRoot",
+				"keep-limits /spec/template/spec/containers/name=app/resources/limits",
+			},
+		},
+		{
+			name:   "Host outside allowed domains",
+			target: ingress,
+			local:  strings.Replace(ingress, "web.mozilla.org", "web.example.com", 1),
+			want:   []string{"allowed-hosts /spec/rules/0/host"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations, err := Evaluate(rules, tc.target, tc.local)
+			if err != nil {
+				t.Fatalf("Evaluate() failed: %v", err)
+			}
+
+			var got []string
+			for _, v := range violations {
+				got = append(got, v.Rule+" "+v.Path)
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Evaluate() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		if _, err := Evaluate(rules, "", "a: [b\n"); err == nil {
+			t.Error("Evaluate() expected an error for invalid YAML")
+		}
+	})
+}
+
+func TestFailed(t *testing.T) {
+	violations := []Violation{{Severity: SeverityInfo}, {Severity: SeverityWarning}}
+
+	testCases := []struct {
+		min  Severity
+		want bool
+	}{
+		{min: SeverityInfo, want: true},
+		{min: SeverityWarning, want: true},
+		{min: SeverityError, want: false},
+	}
+
+	for _, tc := range testCases {
+		if got := Failed(violations, tc.min); got != tc.want {
+			t.Errorf("Failed(%s) = %t, want %t", tc.min, got, tc.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "no new violations") {
+		t.Errorf("Write() with no violations wrote %q", b.String())
+	}
+
+	rules, err := LoadDir(policyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := strings.Replace(strings.Replace(ingress, "web.mozilla.org", "web.example.com", 1)+"---\n"+deployment, "nginx:1.27", "nginx:latest", 1)
+	violations, err := Evaluate(rules, "", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Reset()
+	if err := Write(&b, violations); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+Policy checks: 2 new violations:
+  - [error] no-latest-tag: apps/v1/Deployment/web /spec/template/spec/containers/name=app/image: images must be pinned to a version or digest ("nginx:latest" matches "(:latest|^[^:@]+)$")
+  - [info] allowed-hosts: networking.k8s.io/v1/Ingress/web /spec/rules/0/host: "web.example.com" does not match "^([a-z0-9-]+\\.)*mozilla\\.(org|com)$"
+`
+	if b.String() != want {
+		t.Errorf("Write() =\n%s\nwant:\n%s", b.String(), want)
+	}
+}
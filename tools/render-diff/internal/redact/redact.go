@@ -0,0 +1,150 @@
+// Package redact hides Secret payloads in rendered manifests, so diffs can
+// be shared in CI logs and PR comments without leaking their values
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix marks a redacted value
+const Prefix = "redacted:hmac-sha256:"
+
+// Masked replaces every value masked by Mask
+const Masked = "redacted"
+
+// KeyEnv names the environment variable holding the key for the hashes
+// of redacted values. Without it a random key is used in every run.
+const KeyEnv = "RENDER_DIFF_REDACTION_KEY"
+
+// runKey keys the hashes of redacted values, so they cannot be reversed
+// by guessing values, even short ones. The hashes match between both
+// sides of a diff, but only match across runs if KeyEnv is set.
+var runKey = key()
+
+// key returns the key set in KeyEnv, or a random key
+func key() string {
+	if k := os.Getenv(KeyEnv); k != "" {
+		return k
+	}
+	return rand.Text()
+}
+
+// payloadFields are the Secret fields that hold sensitive values
+var payloadFields = []string{"data", "stringData"}
+
+// Secrets replaces every value in the data and stringData fields of
+// Secret objects with a keyed hash of the value. The hash is stable within
+// a run, so a diff still shows which keys changed without showing their
+// values. Values already replaced by Mask are left as they are.
+func Secrets(render string) (string, error) {
+	return redact(render, hashNode)
+}
+
+// Mask replaces every value in the data and stringData fields of Secret
+// objects with Masked. Unlike the hashes of Secrets it is the same in
+// every run, for renders that are kept, like snapshots, at the cost of
+// not showing which values changed.
+func Mask(render string) (string, error) {
+	return redact(render, maskNode)
+}
+
+// redact calls replace on every value in the data and stringData fields
+// of Secret objects. Only the text of the changed Secrets is replaced, so
+// separators and other objects keep their formatting, and a Secret on one
+// side of a diff does not change how the rest of that side is printed.
+func redact(render string, replace func(*yaml.Node)) (string, error) {
+	docs, err := manifest.Parse(render)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse render: %w", err)
+	}
+
+	var b strings.Builder
+	rest := render
+	for _, doc := range docs {
+		if !isSecret(doc.ID) {
+			continue
+		}
+
+		root := doc.Node
+		if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+			root = root.Content[0]
+		}
+
+		changed := false
+		for _, field := range payloadFields {
+			payload := manifest.Lookup(root, field)
+			if payload == nil || payload.Kind != yaml.MappingNode {
+				continue
+			}
+			for i := 1; i < len(payload.Content); i += 2 {
+				replace(payload.Content[i])
+				changed = true
+			}
+		}
+
+		if !changed {
+			continue
+		}
+
+		// Documents are parsed in order from the render, so the original
+		// text of each is found after the previous one
+		raw := doc.Raw
+		if err := doc.Update(); err != nil {
+			return "", err
+		}
+		i := strings.Index(rest, raw)
+		if i < 0 {
+			return "", fmt.Errorf("failed to find %s in render", doc.ID)
+		}
+		b.WriteString(rest[:i])
+		b.WriteString(doc.Raw)
+		rest = rest[i+len(raw):]
+	}
+
+	if b.Len() == 0 {
+		return render, nil
+	}
+	b.WriteString(rest)
+	return b.String(), nil
+}
+
+// isSecret reports whether an object is a core Secret
+func isSecret(id manifest.ResourceID) bool {
+	return id.Group == "" && id.Kind == "Secret"
+}
+
+// hashNode replaces a value node with a plain string holding its
+// HMAC-SHA256, keyed with runKey. Masked values are kept.
+func hashNode(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Value == Masked {
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(runKey))
+	mac.Write([]byte(node.Value))
+	sum := mac.Sum(nil)
+	setString(node, Prefix+hex.EncodeToString(sum[:])[:16])
+}
+
+// maskNode replaces a value node with Masked
+func maskNode(node *yaml.Node) {
+	setString(node, Masked)
+}
+
+// setString turns node into a plain string scalar holding value
+func setString(node *yaml.Node, value string) {
+	node.Kind = yaml.ScalarNode
+	node.Tag = "!!str"
+	node.Style = 0
+	node.Content = nil
+	node.Value = value
+}
@@ -0,0 +1,157 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	render := `---
+# Source: web/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web
+data:
+  password: aHVudGVyMg==
+stringData:
+  token: |
+    s3cr3t
+---
+# Source: web/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  password: not-a-secret
+`
+
+	got, err := Secrets(render)
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+
+	for _, leaked := range []string{"aHVudGVyMg==", "s3cr3t"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("Secrets() output still contains %q:\n%s", leaked, got)
+		}
+	}
+	for _, want := range []string{
+		"# Source: web/templates/secret.yaml",
+		"  password: " + Prefix,
+		"  token: " + Prefix,
+		"  password: not-a-secret",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Secrets() output missing %q:\n%s", want, got)
+		}
+	}
+
+	// Hashes are stable so both sides of a diff redact to the same value
+	again, err := Secrets(render)
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+	if got != again {
+		t.Error("Secrets() is not stable across calls")
+	}
+
+	changed, err := Secrets(strings.Replace(render, "aHVudGVyMg==", "b3RoZXI=", 1))
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+	if changed == got {
+		t.Error("Secrets() hides that a value changed")
+	}
+}
+
+func TestSecretsWithoutSecrets(t *testing.T) {
+	render := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n"
+
+	got, err := Secrets(render)
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+	if got != render {
+		t.Errorf("Secrets() = %q, want the render unchanged", got)
+	}
+}
+
+func TestSecretsKeyedHash(t *testing.T) {
+	render := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: web\nstringData:\n  pin: \"123456\"\n"
+
+	got, err := Secrets(render)
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+
+	// An unkeyed hash of a short value can be reversed by trying every value
+	sum := sha256.Sum256([]byte("123456"))
+	if strings.Contains(got, hex.EncodeToString(sum[:])[:16]) {
+		t.Errorf("Secrets() output contains an unkeyed hash of the value:\n%s", got)
+	}
+}
+
+func TestMask(t *testing.T) {
+	render := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: web\ndata:\n  password: aHVudGVyMg==\nstringData:\n  pin: \"123456\"\n"
+
+	got, err := Mask(render)
+	if err != nil {
+		t.Fatalf("Mask() failed: %v", err)
+	}
+	for _, leaked := range []string{"aHVudGVyMg==", "123456"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("Mask() output still contains %q:\n%s", leaked, got)
+		}
+	}
+	for _, want := range []string{"  password: " + Masked + "\n", "  pin: " + Masked + "\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Mask() output missing %q:\n%s", want, got)
+		}
+	}
+
+	// Secrets keeps masked values, so a masked render stays the same
+	// across runs with different keys
+	redacted, err := Secrets(got)
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+	if strings.Contains(redacted, Prefix) {
+		t.Errorf("Secrets() hashed a masked value:\n%s", redacted)
+	}
+}
+
+func TestSecretsKeepsFormatting(t *testing.T) {
+	// kustomize renders have no leading separator
+	configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  items:\n  - a\n"
+	secret := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: web\nstringData:\n  token: s3cr3t\n"
+
+	got, err := Secrets(configMap + "---\n" + secret)
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+
+	// Only the Secret changes, so a render with the Secret on one side only
+	// diffs as just the Secret
+	if !strings.HasPrefix(got, configMap+"---\n") {
+		t.Errorf("Secrets() changed the formatting of other objects:\n%s", got)
+	}
+	if strings.Contains(got, "s3cr3t") {
+		t.Errorf("Secrets() output still contains the value:\n%s", got)
+	}
+}
+
+func TestKey(t *testing.T) {
+	t.Setenv(KeyEnv, "ci-key")
+	if got := key(); got != "ci-key" {
+		t.Errorf("key() = %q, want the key from %s", got, KeyEnv)
+	}
+
+	t.Setenv(KeyEnv, "")
+	if key() == key() {
+		t.Error("key() without a key set should be random")
+	}
+}
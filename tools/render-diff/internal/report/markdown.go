@@ -0,0 +1,366 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/capacity"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/deprecation"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/destructive"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/images"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/policy"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/security"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/validate"
+)
+
+// GitHubCommentLimit is the maximum body size of a GitHub PR comment
+const GitHubCommentLimit = 65536
+
+// MarkdownOptions controls how the markdown report is rendered
+type MarkdownOptions struct {
+	// Limit is the maximum size of the report in bytes.
+	// Defaults to GitHubCommentLimit.
+	Limit int
+	// FullDiffPath is the side file mentioned in the report when it is truncated
+	FullDiffPath string
+}
+
+// markdown headings used for each change type
+var changeTitles = map[diff.ChangeType]string{
+	diff.ChangeAdded:    "Added",
+	diff.ChangeModified: "Updated",
+	diff.ChangeRemoved:  "Removed",
+}
+
+// WriteMarkdown writes a GitHub-flavored markdown report with a summary table
+// per result and a collapsible diff per changed resource. When the report
+// would exceed the size limit, diff blocks are dropped from the end, and if
+// the summaries alone do not fit they are cut short at a line as well.
+// WriteMarkdown then returns true so the caller can write the full diff
+// elsewhere.
+func (r *Report) WriteMarkdown(w io.Writer, opts MarkdownOptions) (bool, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = GitHubCommentLimit
+	}
+
+	summaries := make([]string, len(r.Results))
+	blocks := make([][]string, len(r.Results))
+	for i, result := range r.Results {
+		var summary strings.Builder
+		writeMarkdownSummary(&summary, r, result)
+		summaries[i] = summary.String()
+
+		for _, change := range result.Resources {
+			blocks[i] = append(blocks[i], markdownDiffBlock(change, result.Diffs[change.String()]))
+		}
+	}
+
+	omittedResults, omittedDiffs := 0, 0
+	cut := false
+	footer := func(shortened bool) string {
+		notice := fmt.Sprintf("%d resource diffs omitted", omittedDiffs)
+		if shortened {
+			notice = fmt.Sprintf("summaries cut short, %d results and %d resource diffs omitted", omittedResults, omittedDiffs)
+		}
+		return fmt.Sprintf("\n> [!WARNING]\n> Output truncated, %s. The full diff was written to `%s`.\n", notice, opts.FullDiffPath)
+	}
+
+	// Reserve room for the truncation notice up front, so adding it at
+	// the end can never push the report over the limit. The counts are
+	// not known yet, so leave room for their digits.
+	size := len(footer(true)) + 32
+
+	// The headers and summary tables come first. If they alone exceed
+	// the limit, the first one that does not fit is cut at the last line
+	// that does and the later results are dropped.
+	kept := len(r.Results)
+	for i, summary := range summaries {
+		if size+len(summary) <= limit {
+			size += len(summary)
+			continue
+		}
+		summaries[i] = summary[:strings.LastIndex(summary[:max(limit-size, 0)], "\n")+1]
+		size += len(summaries[i])
+		kept, cut = i+1, true
+		omittedResults = len(r.Results) - kept
+		break
+	}
+
+	var out strings.Builder
+	for i := range r.Results {
+		if i < kept {
+			out.WriteString(summaries[i])
+		}
+		for _, block := range blocks[i] {
+			if cut || omittedDiffs > 0 || size+len(block) > limit {
+				omittedDiffs++
+				continue
+			}
+			size += len(block)
+			out.WriteString(block)
+		}
+	}
+
+	truncated := cut || omittedDiffs > 0
+	if truncated {
+		out.WriteString(footer(cut))
+	}
+	if len(r.Results) == 0 {
+		fmt.Fprintf(&out, "### render-diff: `%s`\n\nNo charts or kustomizations were rendered.\n", r.Ref)
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return truncated, err
+}
+
+// writeMarkdownSummary writes the heading and the per-kind summary table
+func writeMarkdownSummary(b *strings.Builder, r *Report, result Result) {
+	title := fmt.Sprintf("### render-diff: `%s` vs. local", r.Ref)
+	if r.ToRef != "" {
+		title = fmt.Sprintf("### render-diff: `%s` vs. `%s`", r.Ref, r.ToRef)
+	}
+	if result.Path != "" {
+		title += fmt.Sprintf(" in `%s`", result.Path)
+	}
+	if result.Environment != "" {
+		title += fmt.Sprintf(" (`%s`)", result.Environment)
+	}
+	fmt.Fprintf(b, "%s\n\n", title)
+
+	writeMarkdownDestructive(b, result.Destructive)
+	writeMarkdownValidation(b, result.Validation)
+	writeMarkdownPolicy(b, result.Policy)
+	writeMarkdownSecurity(b, result.Security)
+	writeMarkdownDeprecations(b, r.KubeVersion, result.Deprecations)
+	defer writeMarkdownHidden(b, r.Filter, result)
+
+	if len(result.Resources) == 0 {
+		b.WriteString("No differences found between rendered manifests.\n\n")
+		return
+	}
+
+	fmt.Fprintf(b, "**%d changed** (%d updated, %d added, %d removed) in `%s`\n\n",
+		len(result.Resources), result.Summary.Modified, result.Summary.Added, result.Summary.Removed, result.To)
+
+	// Count changes per kind
+	type counts struct{ added, modified, removed int }
+	perKind := make(map[string]*counts)
+	for _, change := range result.Resources {
+		c, ok := perKind[change.Kind]
+		if !ok {
+			c = &counts{}
+			perKind[change.Kind] = c
+		}
+		switch change.Change {
+		case diff.ChangeAdded:
+			c.added++
+		case diff.ChangeRemoved:
+			c.removed++
+		default:
+			c.modified++
+		}
+	}
+
+	kinds := make([]string, 0, len(perKind))
+	for kind := range perKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	b.WriteString("| Kind | Added | Updated | Removed |\n")
+	b.WriteString("| :--- | ---: | ---: | ---: |\n")
+	for _, kind := range kinds {
+		c := perKind[kind]
+		fmt.Fprintf(b, "| %s | %d | %d | %d |\n", kind, c.added, c.modified, c.removed)
+	}
+	b.WriteString("\n")
+
+	writeMarkdownImages(b, result.Images)
+	writeMarkdownCapacity(b, result.Capacity)
+}
+
+// writeMarkdownImages writes a table of the containers whose image changed
+func writeMarkdownImages(b *strings.Builder, changes []images.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	b.WriteString("**Image changes**\n\n")
+	b.WriteString("| Workload | Container | Old | New | Flags |\n")
+	b.WriteString("| :--- | :--- | :--- | :--- | :--- |\n")
+	for _, c := range changes {
+		fmt.Fprintf(b, "| `%s` | %s | %s | %s | %s |\n", c.ResourceID, c.ContainerName(), markdownCode(c.Old), markdownCode(c.New), images.JoinFlags(c.Flags))
+	}
+	b.WriteString("\n")
+}
+
+// writeMarkdownCapacity writes a table of the capacity delta per workload
+func writeMarkdownCapacity(b *strings.Builder, changes []capacity.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "**Capacity**: %s\n\n", capacity.Summary(changes))
+	b.WriteString("| Workload | Replicas | CPU requests | CPU limits | Memory requests | Memory limits |\n")
+	b.WriteString("| :--- | :--- | ---: | ---: | ---: | ---: |\n")
+	rows := capacity.Rows(changes)
+	for i, row := range rows {
+		cells := slices.Clone(row)
+		if i == len(rows)-1 {
+			cells[0] = "**Total**"
+		} else {
+			cells[0] = "`" + cells[0] + "`"
+		}
+		fmt.Fprintf(b, "| %s |\n", strings.Join(cells, " | "))
+	}
+	b.WriteString("\n")
+}
+
+// markdownCode formats s as inline code, or "-" when it is empty
+func markdownCode(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return "`" + s + "`"
+}
+
+// writeMarkdownHidden notes how many objects were excluded by filters
+// and how many changes were hidden by ignore rules
+func writeMarkdownHidden(b *strings.Builder, filter string, result Result) {
+	if result.Filtered > 0 {
+		objectStr := "object"
+		if result.Filtered != 1 {
+			objectStr = "objects"
+		}
+		fmt.Fprintf(b, "_%d %s excluded by filters: `%s`._\n\n", result.Filtered, objectStr, filter)
+	}
+	if result.Suppressed > 0 {
+		changeStr := "change"
+		if result.Suppressed != 1 {
+			changeStr = "changes"
+		}
+		fmt.Fprintf(b, "_%d %s suppressed by ignore rules._\n\n", result.Suppressed, changeStr)
+	}
+}
+
+// writeMarkdownDestructive writes the changes that require replacement
+// or risk data loss
+func writeMarkdownDestructive(b *strings.Builder, changes []destructive.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	changeStr := "change"
+	if len(changes) != 1 {
+		changeStr = "changes"
+	}
+	fmt.Fprintf(b, "> [!CAUTION]\n> **%d destructive %s, requiring replacement or risking data loss**\n>\n", len(changes), changeStr)
+	for _, change := range changes {
+		field := ""
+		if change.Field != "" {
+			field = fmt.Sprintf(" `%s`", change.Field)
+		}
+		fmt.Fprintf(b, "> - **%s** `%s`%s: %s\n", change.Risk.Label(), change.ResourceID, field, change.Reason)
+	}
+	b.WriteString("\n")
+}
+
+// writeMarkdownValidation writes the objects that newly fail schema validation
+func writeMarkdownValidation(b *strings.Builder, failures []validate.Failure) {
+	if len(failures) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "> [!CAUTION]\n> **%d newly failing schema validation**\n>\n", len(failures))
+	for _, failure := range failures {
+		fmt.Fprintf(b, "> - `%s`\n", failure.String())
+		for _, e := range failure.Errors {
+			fmt.Fprintf(b, ">   - `%s`\n", e)
+		}
+	}
+	b.WriteString("\n")
+}
+
+// writeMarkdownPolicy writes the new policy violations, most serious first.
+// The callout is only a caution when a violation has error severity.
+func writeMarkdownPolicy(b *strings.Builder, violations []policy.Violation) {
+	if len(violations) == 0 {
+		return
+	}
+
+	callout := "WARNING"
+	if policy.Failed(violations, policy.SeverityError) {
+		callout = "CAUTION"
+	}
+	violationStr := "violation"
+	if len(violations) != 1 {
+		violationStr = "violations"
+	}
+	fmt.Fprintf(b, "> [!%s]\n> **%d new policy %s**\n>\n", callout, len(violations), violationStr)
+	for _, v := range policy.Sorted(violations) {
+		fmt.Fprintf(b, "> - **%s** `%s` `%s` `%s`: %s\n", v.Severity, v.Rule, v.ResourceID, v.Path, v.Description())
+	}
+	b.WriteString("\n")
+}
+
+// writeMarkdownSecurity writes the RBAC and network exposure changes
+func writeMarkdownSecurity(b *strings.Builder, findings []security.Finding) {
+	if len(findings) == 0 {
+		return
+	}
+
+	changeStr := "change needs"
+	if len(findings) != 1 {
+		changeStr = "changes need"
+	}
+	fmt.Fprintf(b, "> [!IMPORTANT]\n> **%d RBAC or network exposure %s a security review**\n>\n", len(findings), changeStr)
+	for _, f := range findings {
+		fmt.Fprintf(b, "> - **%s** `%s`: %s\n", f.Change, f.ResourceID, f.Detail)
+	}
+	b.WriteString("\n")
+}
+
+// writeMarkdownDeprecations writes the objects using API versions deprecated
+// or removed in kubeVersion. The callout is only a caution when an API
+// version is removed.
+func writeMarkdownDeprecations(b *strings.Builder, kubeVersion string, findings []deprecation.Finding) {
+	if len(findings) == 0 {
+		return
+	}
+
+	callout := "WARNING"
+	for _, f := range findings {
+		if f.Status == deprecation.StatusRemoved {
+			callout = "CAUTION"
+		}
+	}
+	objectStr := "object uses"
+	if len(findings) != 1 {
+		objectStr = "objects use"
+	}
+	fmt.Fprintf(b, "> [!%s]\n> **%d %s an API version deprecated or removed in Kubernetes %s**\n>\n", callout, len(findings), objectStr, kubeVersion)
+	for _, f := range findings {
+		fmt.Fprintf(b, "> - **%s** `%s`: %s\n", f.Status, f.ResourceID, f.Description())
+	}
+	b.WriteString("\n")
+}
+
+// markdownDiffBlock returns a collapsible details block for one resource
+func markdownDiffBlock(change diff.ResourceChange, resourceDiff string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<details>\n<summary>%s <code>%s</code></summary>\n\n", changeTitles[change.Change], change.String())
+
+	// Use a longer fence if the diff itself contains backticks
+	fence := "```"
+	for strings.Contains(resourceDiff, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(&b, "%sdiff\n%s\n%s\n\n</details>\n\n", fence, strings.TrimRight(resourceDiff, "\n"), fence)
+
+	return b.String()
+}
@@ -0,0 +1,352 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/capacity"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/deprecation"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/destructive"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/images"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/policy"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/security"
+)
+
+// markdownResult builds a result with n modified ConfigMaps and a fake diff for each
+func markdownResult(n int) Result {
+	var changes []diff.ResourceChange
+	diffs := make(map[string]string)
+	for i := range n {
+		change := diff.ResourceChange{
+			ResourceID: manifest.ResourceID{Version: "v1", Kind: "ConfigMap", Name: fmt.Sprintf("cm-%d", i)},
+			Change:     diff.ChangeModified,
+		}
+		changes = append(changes, change)
+		diffs[change.String()] = fmt.Sprintf("-  key: old-%d\n+  key: new-%d\n", i, i)
+	}
+	changes = append(changes, diff.ResourceChange{
+		ResourceID: manifest.ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web"},
+		Change:     diff.ChangeAdded,
+	})
+
+	result := NewResult("", "main/chart", "local/chart", changes)
+	result.Diffs = diffs
+	return result
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	rep := New("main")
+	result := markdownResult(2)
+	result.Suppressed = 3
+	result.Filtered = 1
+	rep.Filter = "kind=ConfigMap,Deployment"
+	rep.Results = append(rep.Results, result)
+
+	var buf bytes.Buffer
+	truncated, err := rep.WriteMarkdown(&buf, MarkdownOptions{FullDiffPath: "full.diff"})
+	if err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+	if truncated {
+		t.Error("WriteMarkdown() truncated a small report")
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"### render-diff: `main` vs. local",
+		"| ConfigMap | 0 | 2 | 0 |",
+		"| Deployment | 1 | 0 | 0 |",
+		"<summary>Updated <code>v1/ConfigMap/cm-0</code></summary>",
+		"<summary>Added <code>apps/v1/Deployment/web</code></summary>",
+		"```diff\n-  key: old-1\n+  key: new-1\n```",
+		"_3 changes suppressed by ignore rules._",
+		"_1 object excluded by filters: `kind=ConfigMap,Deployment`._",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteMarkdown() output missing %q. Got:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteMarkdownToRef(t *testing.T) {
+	rep := New("main")
+	rep.ToRef = "release-2026.10"
+	rep.Results = append(rep.Results, markdownResult(1))
+
+	var buf bytes.Buffer
+	if _, err := rep.WriteMarkdown(&buf, MarkdownOptions{}); err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+	if want := "### render-diff: `main` vs. `release-2026.10`"; !strings.Contains(buf.String(), want) {
+		t.Errorf("WriteMarkdown() output missing %q. Got:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteMarkdownDestructive(t *testing.T) {
+	rep := New("main")
+	result := markdownResult(1)
+	result.Destructive = []destructive.Change{
+		{
+			ResourceID: manifest.ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web"},
+			Risk:       destructive.RiskReplacement,
+			Field:      "/spec/selector",
+			Reason:     "the selector is immutable",
+		},
+		{
+			ResourceID: manifest.ResourceID{Version: "v1", Kind: "PersistentVolumeClaim", Name: "data"},
+			Risk:       destructive.RiskDataLoss,
+			Reason:     "removing the claim can delete its volume and data",
+		},
+	}
+	rep.Results = append(rep.Results, result)
+
+	var buf bytes.Buffer
+	if _, err := rep.WriteMarkdown(&buf, MarkdownOptions{}); err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"> [!CAUTION]\n> **2 destructive changes, requiring replacement or risking data loss**",
+		"> - **requires replacement** `apps/v1/Deployment/web` `/spec/selector`: the selector is immutable",
+		"> - **data loss risk** `v1/PersistentVolumeClaim/data`: removing the claim",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteMarkdown() output missing %q. Got:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteMarkdownPolicy(t *testing.T) {
+	violation := func(severity policy.Severity) policy.Violation {
+		return policy.Violation{
+			ResourceID: manifest.ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web"},
+			Rule:       "no-latest-tag",
+			Severity:   severity,
+			Path:       "/spec/template/spec/containers/name=app/image",
+			Message:    "images must be pinned",
+			Detail:     `"nginx:latest" matches ":latest$"`,
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		violations []policy.Violation
+		want       []string
+	}{
+		{
+			name:       "Warnings only",
+			violations: []policy.Violation{violation(policy.SeverityWarning)},
+			want: []string{
+				"> [!WARNING]\n> **1 new policy violation**",
+				"> - **warning** `no-latest-tag` `apps/v1/Deployment/web` `/spec/template/spec/containers/name=app/image`: images must be pinned (\"nginx:latest\" matches \":latest$\")",
+			},
+		},
+		{
+			name:       "Errors first",
+			violations: []policy.Violation{violation(policy.SeverityInfo), violation(policy.SeverityError)},
+			want: []string{
+				"> [!CAUTION]\n> **2 new policy violations**\n>\n> - **error**",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rep := New("main")
+			result := markdownResult(1)
+			result.Policy = tc.violations
+			rep.Results = append(rep.Results, result)
+
+			var buf bytes.Buffer
+			if _, err := rep.WriteMarkdown(&buf, MarkdownOptions{}); err != nil {
+				t.Fatalf("WriteMarkdown() failed: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("WriteMarkdown() output missing %q. Got:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMarkdownImages(t *testing.T) {
+	rep := New("main")
+	result := markdownResult(1)
+	result.Images = []images.Change{
+		{
+			ResourceID: manifest.ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web"},
+			Container:  "app",
+			Old:        "app:1.3.0",
+			New:        "app:1.2.0",
+			Flags:      []images.Flag{images.FlagDowngrade},
+		},
+		{
+			ResourceID: manifest.ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web"},
+			Container:  "migrate",
+			Init:       true,
+			New:        "app:1.2.0",
+		},
+	}
+	rep.Results = append(rep.Results, result)
+
+	var buf bytes.Buffer
+	if _, err := rep.WriteMarkdown(&buf, MarkdownOptions{}); err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+
+	want := "| Deployment | 1 | 0 | 0 |\n\n**Image changes**\n\n" +
+		"| Workload | Container | Old | New | Flags |\n" +
+		"| :--- | :--- | :--- | :--- | :--- |\n" +
+		"| `apps/v1/Deployment/web` | app | `app:1.3.0` | `app:1.2.0` | downgrade |\n" +
+		"| `apps/v1/Deployment/web` | migrate (init) | - | `app:1.2.0` |  |\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("WriteMarkdown() output missing %q. Got:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteMarkdownCapacity(t *testing.T) {
+	rep := New("main")
+	result := markdownResult(1)
+	result.Capacity = []capacity.Change{
+		{
+			ResourceID: manifest.ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web"},
+			Before:     &capacity.Footprint{MinReplicas: 2, MaxReplicas: 2, Pod: capacity.Usage{Requests: capacity.Resources{CPU: 500, Memory: 1 << 29}}},
+			After:      &capacity.Footprint{MinReplicas: 4, MaxReplicas: 4, Pod: capacity.Usage{Requests: capacity.Resources{CPU: 500, Memory: 1 << 29}}},
+		},
+	}
+	rep.Results = append(rep.Results, result)
+
+	var buf bytes.Buffer
+	if _, err := rep.WriteMarkdown(&buf, MarkdownOptions{}); err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+
+	want := "**Capacity**: CPU requests +1 vCPU, memory requests +1Gi\n\n" +
+		"| Workload | Replicas | CPU requests | CPU limits | Memory requests | Memory limits |\n" +
+		"| :--- | :--- | ---: | ---: | ---: | ---: |\n" +
+		"| `apps/v1/Deployment/web` | 2 -> 4 | +1 vCPU | 0 | +1Gi | 0 |\n" +
+		"| **Total** |  | +1 vCPU | 0 | +1Gi | 0 |\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("WriteMarkdown() output missing %q. Got:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteMarkdownSecurity(t *testing.T) {
+	rep := New("main")
+	result := markdownResult(1)
+	result.Security = []security.Finding{
+		{
+			ResourceID: manifest.ResourceID{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "reader"},
+			Category:   security.CategoryRBAC,
+			Change:     security.ChangeAdded,
+			Detail:     "grants delete pods",
+		},
+	}
+	rep.Results = append(rep.Results, result)
+
+	var buf bytes.Buffer
+	if _, err := rep.WriteMarkdown(&buf, MarkdownOptions{}); err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+
+	want := "> [!IMPORTANT]\n> **1 RBAC or network exposure change needs a security review**\n>\n" +
+		"> - **added** `rbac.authorization.k8s.io/v1/ClusterRole/reader`: grants delete pods\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("WriteMarkdown() output missing %q. Got:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteMarkdownDeprecations(t *testing.T) {
+	rep := New("main")
+	rep.KubeVersion = "1.30"
+	result := markdownResult(1)
+	result.Deprecations = []deprecation.Finding{
+		{
+			ResourceID:  manifest.ResourceID{Group: "batch", Version: "v1beta1", Kind: "CronJob", Name: "cleanup"},
+			Status:      deprecation.StatusRemoved,
+			Since:       "1.25",
+			Replacement: "batch/v1",
+			Introduced:  true,
+		},
+	}
+	rep.Results = append(rep.Results, result)
+
+	var buf bytes.Buffer
+	if _, err := rep.WriteMarkdown(&buf, MarkdownOptions{}); err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+
+	want := "> [!CAUTION]\n> **1 object uses an API version deprecated or removed in Kubernetes 1.30**\n>\n" +
+		"> - **removed** `batch/v1beta1/CronJob/cleanup`: removed in Kubernetes 1.25, use batch/v1 (introduced by this change)\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("WriteMarkdown() output missing %q. Got:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteMarkdownTruncates(t *testing.T) {
+	rep := New("main")
+	rep.Results = append(rep.Results, markdownResult(200))
+
+	limit := 4000
+	var buf bytes.Buffer
+	truncated, err := rep.WriteMarkdown(&buf, MarkdownOptions{Limit: limit, FullDiffPath: "full.diff"})
+	if err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+	if !truncated {
+		t.Fatal("WriteMarkdown() did not report truncation")
+	}
+
+	out := buf.String()
+	if len(out) > limit {
+		t.Errorf("WriteMarkdown() wrote %d bytes, limit is %d", len(out), limit)
+	}
+	if !strings.Contains(out, "The full diff was written to `full.diff`") {
+		t.Errorf("WriteMarkdown() output missing truncation notice. Got:\n%s", out)
+	}
+	// The summary table is always kept and details blocks are never cut in half
+	if !strings.Contains(out, "| ConfigMap | 0 | 200 | 0 |") {
+		t.Error("WriteMarkdown() dropped the summary table")
+	}
+	if strings.Count(out, "<details>") != strings.Count(out, "</details>") {
+		t.Error("WriteMarkdown() left an unbalanced details block")
+	}
+}
+
+func TestWriteMarkdownTruncatesSummaries(t *testing.T) {
+	rep := New("main")
+	for i := range 300 {
+		result := markdownResult(20)
+		result.Environment = fmt.Sprintf("env-%d", i)
+		rep.Results = append(rep.Results, result)
+	}
+
+	var buf bytes.Buffer
+	truncated, err := rep.WriteMarkdown(&buf, MarkdownOptions{FullDiffPath: "full.diff"})
+	if err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+	if !truncated {
+		t.Fatal("WriteMarkdown() did not report truncation")
+	}
+
+	out := buf.String()
+	if len(out) > GitHubCommentLimit {
+		t.Errorf("WriteMarkdown() wrote %d bytes, limit is %d", len(out), GitHubCommentLimit)
+	}
+	if !strings.Contains(out, "summaries cut short") || !strings.Contains(out, "The full diff was written to `full.diff`") {
+		t.Errorf("WriteMarkdown() output missing truncation notice. Got:\n%s", out[max(len(out)-500, 0):])
+	}
+	if strings.Contains(out, "<details>") {
+		t.Error("WriteMarkdown() wrote diff blocks although the summaries did not fit")
+	}
+	if !strings.Contains(out, "(`env-0`)") || strings.Contains(out, "(`env-299`)") {
+		t.Error("WriteMarkdown() did not keep the first results and drop the last")
+	}
+}
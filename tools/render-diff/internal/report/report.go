@@ -0,0 +1,144 @@
+// Package report builds machine-readable and pull request friendly
+// reports from the changes found between a target render and a local render
+package report
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/capacity"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/deprecation"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/destructive"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/images"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/policy"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/security"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/validate"
+)
+
+// SchemaVersion is bumped whenever a field is renamed or removed
+// from the JSON output. Adding new fields does not change it.
+const SchemaVersion = 1
+
+// Report is the top level document written by --output json
+type Report struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Ref is the target ref, or the values files of the first side
+	// of a drift comparison
+	Ref string `json:"ref"`
+	// ToRef is the ref compared against Ref with --to, or the values
+	// files of the second side of a drift comparison. It is empty when
+	// the working tree was compared.
+	ToRef string `json:"toRef,omitempty"`
+	// Filter describes the object selection filters, if any were used
+	Filter string `json:"filter,omitempty"`
+	// KubeVersion is the Kubernetes version API versions were checked
+	// against. It is omitted when no version was set and they were not
+	// checked.
+	KubeVersion string   `json:"kubeVersion,omitempty"`
+	Results     []Result `json:"results"`
+}
+
+// Result holds the changes for a single comparison. Path is empty unless
+// the run was started with --changed, and Environment is empty unless
+// the run was started with --env or --matrix.
+type Result struct {
+	Path        string                `json:"path,omitempty"`
+	Environment string                `json:"environment,omitempty"`
+	From        string                `json:"from"`
+	To          string                `json:"to"`
+	Summary     Summary               `json:"summary"`
+	Resources   []diff.ResourceChange `json:"resources"`
+	// Filtered counts the objects excluded by selection filters
+	Filtered int `json:"filtered"`
+	// Suppressed counts the changed fields removed by ignore rules
+	Suppressed int `json:"suppressed"`
+	// Validation lists objects that newly fail schema validation.
+	// It is omitted when --validate is not set or nothing newly fails.
+	Validation []validate.Failure `json:"validation,omitempty"`
+	// Destructive lists changes that require replacing an object or
+	// risk data loss. It is omitted when there are none.
+	Destructive []destructive.Change `json:"destructive,omitempty"`
+	// Policy lists new violations of the policy rules. It is omitted
+	// when --policy-dir is not set or nothing new violates a rule.
+	Policy []policy.Violation `json:"policy,omitempty"`
+	// Images lists containers whose image changed. It is omitted when
+	// no image changed.
+	Images []images.Change `json:"images,omitempty"`
+	// Capacity lists workloads whose replicas, requests or limits
+	// changed. It is omitted when there are none.
+	Capacity []capacity.Change `json:"capacity,omitempty"`
+	// Security lists RBAC and network exposure changes that need a
+	// security review. It is omitted when there are none.
+	Security []security.Finding `json:"security,omitempty"`
+	// Deprecations lists objects of the local render whose API version is
+	// deprecated or removed in the target Kubernetes version. It is omitted
+	// when there are none.
+	Deprecations []deprecation.Finding `json:"deprecations,omitempty"`
+
+	// Diffs holds the unified diff of each changed object keyed by its
+	// ResourceID string. It is only used by the markdown report.
+	Diffs map[string]string `json:"-"`
+	// FullDiff is the unified diff of the whole render, written to a
+	// side file when the markdown report is truncated
+	FullDiff string `json:"-"`
+}
+
+// Summary counts the changed objects by change type
+type Summary struct {
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Modified int `json:"modified"`
+}
+
+// New returns an empty report for the given ref
+func New(ref string) *Report {
+	return &Report{
+		SchemaVersion: SchemaVersion,
+		Ref:           ref,
+		Results:       []Result{},
+	}
+}
+
+// NewResult builds a result from the changes found in one comparison
+func NewResult(environment, from, to string, changes []diff.ResourceChange) Result {
+	result := Result{
+		Environment: environment,
+		From:        from,
+		To:          to,
+		Resources:   changes,
+	}
+	if result.Resources == nil {
+		result.Resources = []diff.ResourceChange{}
+	}
+
+	for _, change := range changes {
+		switch change.Change {
+		case diff.ChangeAdded:
+			result.Summary.Added++
+		case diff.ChangeRemoved:
+			result.Summary.Removed++
+		default:
+			result.Summary.Modified++
+		}
+	}
+
+	return result
+}
+
+// HasChanges reports whether any result contains a changed object
+func (r *Report) HasChanges() bool {
+	for _, result := range r.Results {
+		if len(result.Resources) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
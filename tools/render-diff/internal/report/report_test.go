@@ -0,0 +1,66 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/diff"
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+)
+
+func TestNewResult(t *testing.T) {
+	changes := []diff.ResourceChange{
+		{ResourceID: manifest.ResourceID{Version: "v1", Kind: "ConfigMap", Name: "a"}, Change: diff.ChangeAdded},
+		{ResourceID: manifest.ResourceID{Version: "v1", Kind: "ConfigMap", Name: "b"}, Change: diff.ChangeModified},
+		{ResourceID: manifest.ResourceID{Version: "v1", Kind: "ConfigMap", Name: "c"}, Change: diff.ChangeModified},
+	}
+
+	result := NewResult("prod", "main/chart", "local/chart", changes)
+
+	want := Summary{Added: 1, Modified: 2}
+	if result.Summary != want {
+		t.Errorf("Summary = %+v, want %+v", result.Summary, want)
+	}
+
+	if empty := NewResult("", "a", "b", nil); empty.Resources == nil {
+		t.Error("Resources should be an empty slice, not nil, so it encodes as []")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	rep := New("main")
+	rep.Results = append(rep.Results, NewResult("", "main/chart", "local/chart", []diff.ResourceChange{
+		{
+			ResourceID: manifest.ResourceID{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web"},
+			Change:     diff.ChangeModified,
+			Fields:     []diff.FieldChange{{Path: "/spec/replicas", Change: diff.ChangeModified, Before: 1, After: 2}},
+		},
+	}))
+
+	var buf bytes.Buffer
+	if err := rep.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() produced invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	if decoded["schemaVersion"] != float64(SchemaVersion) {
+		t.Errorf("schemaVersion = %v, want %d", decoded["schemaVersion"], SchemaVersion)
+	}
+
+	results := decoded["results"].([]any)
+	resource := results[0].(map[string]any)["resources"].([]any)[0].(map[string]any)
+	for key, want := range map[string]any{"group": "apps", "kind": "Deployment", "name": "web", "change": "modified"} {
+		if resource[key] != want {
+			t.Errorf("resource[%q] = %v, want %v", key, resource[key], want)
+		}
+	}
+
+	if !rep.HasChanges() {
+		t.Error("HasChanges() = false, want true")
+	}
+}
@@ -0,0 +1,526 @@
+// Package security reports changes to RBAC permissions and network
+// exposure between two renders, which need a security review: granted
+// permissions, bound subjects, exposed hosts and ports, and loosened
+// network policies and IAP settings
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Category groups findings for reviewers
+type Category string
+
+const (
+	CategoryRBAC    Category = "rbac"
+	CategoryNetwork Category = "network"
+)
+
+// Change describes how a finding changes access
+type Change string
+
+const (
+	// ChangeAdded grants a permission or exposes a host, port or subject
+	ChangeAdded Change = "added"
+	// ChangeRemoved revokes a permission or stops exposing something
+	ChangeRemoved Change = "removed"
+	// ChangeLoosened weakens a restriction, e.g. a network policy or IAP
+	ChangeLoosened Change = "loosened"
+	// ChangeModified changes access in a way that needs a closer look
+	ChangeModified Change = "modified"
+)
+
+// Finding is a security relevant change to one object
+type Finding struct {
+	manifest.ResourceID
+	Category Category `json:"category"`
+	Change   Change   `json:"change"`
+	Detail   string   `json:"detail"`
+}
+
+// String describes the finding on a single line
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.ResourceID, f.Detail)
+}
+
+// finding is a change found by a detector, before it is attached to an object
+type finding struct {
+	change Change
+	detail string
+}
+
+// detector compares two versions of an object. Either node is nil when
+// the object does not exist in that render.
+type detector struct {
+	category Category
+	detect   func(before, after *yaml.Node) []finding
+}
+
+// detectors maps the kinds that need a security review, by API group, to
+// their detector
+var detectors = map[[2]string]detector{
+	{"rbac.authorization.k8s.io", "Role"}:               {CategoryRBAC, detectRole},
+	{"rbac.authorization.k8s.io", "ClusterRole"}:        {CategoryRBAC, detectRole},
+	{"rbac.authorization.k8s.io", "RoleBinding"}:        {CategoryRBAC, detectBinding},
+	{"rbac.authorization.k8s.io", "ClusterRoleBinding"}: {CategoryRBAC, detectBinding},
+	{"networking.k8s.io", "NetworkPolicy"}:              {CategoryNetwork, detectNetworkPolicy},
+	{"", "Service"}:                                     {CategoryNetwork, detectService},
+	{"networking.k8s.io", "Ingress"}:                    {CategoryNetwork, detectIngress},
+	{"gateway.networking.k8s.io", "Gateway"}:            {CategoryNetwork, detectGateway},
+	{"gateway.networking.k8s.io", "HTTPRoute"}:          {CategoryNetwork, detectRoute},
+	{"cloud.google.com", "BackendConfig"}:               {CategoryNetwork, detectIAP("spec", "iap", "enabled")},
+	{"networking.gke.io", "GCPBackendPolicy"}:           {CategoryNetwork, detectIAP("spec", "default", "iap", "enabled")},
+}
+
+// Compare returns the security relevant changes between two renders,
+// ordered as the objects appear in the local render, followed by removed
+// objects
+func Compare(target, local string) ([]Finding, error) {
+	targetDocs, err := manifest.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target render: %w", err)
+	}
+	localDocs, err := manifest.Parse(local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse local render: %w", err)
+	}
+
+	targetIndex := manifest.Index(targetDocs)
+	localIndex := manifest.Index(localDocs)
+
+	var findings []Finding
+	check := func(id manifest.ResourceID, before, after *manifest.Document) {
+		d, ok := detectors[[2]string{id.Group, id.Kind}]
+		if !ok {
+			return
+		}
+		for _, f := range d.detect(root(before), root(after)) {
+			findings = append(findings, Finding{ResourceID: id, Category: d.category, Change: f.change, Detail: f.detail})
+		}
+	}
+
+	for _, doc := range localDocs {
+		check(doc.ID, targetIndex[doc.ID.String()], doc)
+	}
+	for _, doc := range targetDocs {
+		if _, ok := localIndex[doc.ID.String()]; !ok {
+			check(doc.ID, doc, nil)
+		}
+	}
+
+	return findings, nil
+}
+
+// root returns the mapping node of a document, or nil
+func root(doc *manifest.Document) *yaml.Node {
+	if doc == nil {
+		return nil
+	}
+	node := doc.Node
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	return node
+}
+
+// detectRole lists the permissions a Role or ClusterRole gained or lost
+func detectRole(before, after *yaml.Node) []finding {
+	return compareSets(permissions(before), permissions(after),
+		func(p string) finding { return finding{ChangeAdded, "grants " + p} },
+		func(p string) finding { return finding{ChangeRemoved, "no longer grants " + p} })
+}
+
+// permissions expands the rules of a role into "verb resource" strings
+func permissions(role *yaml.Node) []string {
+	var result []string
+	for _, rule := range sequence(lookup(role, "rules")) {
+		verbs := scalars(lookup(rule, "verbs"))
+
+		var targets []string
+		for _, group := range scalars(lookup(rule, "apiGroups")) {
+			for _, resource := range scalars(lookup(rule, "resources")) {
+				if group != "" {
+					resource = group + "/" + resource
+				}
+				targets = append(targets, resource)
+			}
+		}
+		targets = append(targets, scalars(lookup(rule, "nonResourceURLs"))...)
+		if names := scalars(lookup(rule, "resourceNames")); len(names) > 0 {
+			for i := range targets {
+				targets[i] += " (" + strings.Join(names, ", ") + ")"
+			}
+		}
+
+		for _, verb := range verbs {
+			for _, target := range targets {
+				result = append(result, verb+" "+target)
+			}
+		}
+	}
+	return result
+}
+
+// detectBinding lists the subjects bound or unbound by a RoleBinding or
+// ClusterRoleBinding, and changes of the bound role
+func detectBinding(before, after *yaml.Node) []finding {
+	role := roleRef(after)
+	if after == nil {
+		role = roleRef(before)
+	}
+
+	var findings []finding
+	if before != nil && after != nil && roleRef(before) != role {
+		findings = append(findings, finding{ChangeModified, fmt.Sprintf("binds %s instead of %s", role, roleRef(before))})
+	}
+	return append(findings, compareSets(subjects(before), subjects(after),
+		func(s string) finding { return finding{ChangeAdded, fmt.Sprintf("binds %s to %s", s, role)} },
+		func(s string) finding {
+			return finding{ChangeRemoved, fmt.Sprintf("no longer binds %s to %s", s, role)}
+		})...)
+}
+
+// roleRef describes the role of a binding as "Kind/name"
+func roleRef(binding *yaml.Node) string {
+	ref := lookup(binding, "roleRef")
+	return scalar(ref, "kind") + "/" + scalar(ref, "name")
+}
+
+// subjects describes the subjects of a binding as "Kind [namespace/]name"
+func subjects(binding *yaml.Node) []string {
+	var result []string
+	for _, subject := range sequence(lookup(binding, "subjects")) {
+		name := scalar(subject, "name")
+		if namespace := scalar(subject, "namespace"); namespace != "" {
+			name = namespace + "/" + name
+		}
+		result = append(result, scalar(subject, "kind")+" "+name)
+	}
+	return result
+}
+
+// detectNetworkPolicy reports network policies that allow more traffic:
+// removed policies, policy types and pod selectors that isolate fewer
+// pods, and added ingress or egress rules
+func detectNetworkPolicy(before, after *yaml.Node) []finding {
+	switch {
+	case after == nil:
+		return []finding{{ChangeLoosened, "removed, the pods it selected are no longer isolated by it"}}
+	case before == nil:
+		return []finding{{ChangeAdded, fmt.Sprintf("isolates pods selected by %s", compact(lookup(after, "spec", "podSelector")))}}
+	}
+
+	var findings []finding
+	beforeSelector, afterSelector := lookup(before, "spec", "podSelector"), lookup(after, "spec", "podSelector")
+	if compact(beforeSelector) != compact(afterSelector) {
+		change := ChangeModified
+		if len(subtract(constraints(afterSelector), constraints(beforeSelector))) > 0 {
+			// Every added constraint selects fewer pods to isolate
+			change = ChangeLoosened
+		}
+		findings = append(findings, finding{change, fmt.Sprintf("pod selector changed from %s to %s", compact(beforeSelector), compact(afterSelector))})
+	}
+
+	for _, policyType := range subtract(scalars(lookup(before, "spec", "policyTypes")), scalars(lookup(after, "spec", "policyTypes"))) {
+		findings = append(findings, finding{ChangeLoosened, "no longer restricts " + strings.ToLower(policyType) + " traffic"})
+	}
+
+	for _, direction := range []string{"ingress", "egress"} {
+		findings = append(findings, compareSets(rules(before, direction), rules(after, direction),
+			func(r string) finding { return finding{ChangeLoosened, fmt.Sprintf("allows %s %s", direction, r)} },
+			func(r string) finding {
+				return finding{ChangeRemoved, fmt.Sprintf("no longer allows %s %s", direction, r)}
+			})...)
+	}
+	return findings
+}
+
+// constraints lists the label requirements of a selector
+func constraints(selector *yaml.Node) []string {
+	var result []string
+	labels := lookup(selector, "matchLabels")
+	for i := 0; labels != nil && i+1 < len(labels.Content); i += 2 {
+		result = append(result, labels.Content[i].Value+"="+labels.Content[i+1].Value)
+	}
+	for _, expression := range sequence(lookup(selector, "matchExpressions")) {
+		result = append(result, compact(expression))
+	}
+	return result
+}
+
+// rules returns the ingress or egress rules of a network policy as JSON
+func rules(policy *yaml.Node, direction string) []string {
+	var result []string
+	for _, rule := range sequence(lookup(policy, "spec", direction)) {
+		result = append(result, compact(rule))
+	}
+	return result
+}
+
+// detectService reports ports exposed outside the cluster by LoadBalancer
+// and NodePort services, source ranges that allow more clients, and load
+// balancers that are no longer internal
+func detectService(before, after *yaml.Node) []finding {
+	findings := compareSets(exposedPorts(before), exposedPorts(after),
+		func(p string) finding { return finding{ChangeAdded, "exposes " + p} },
+		func(p string) finding { return finding{ChangeRemoved, "no longer exposes " + p} })
+	if !isExposed(after) {
+		return findings
+	}
+
+	beforeRanges, afterRanges := scalars(lookup(before, "spec", "loadBalancerSourceRanges")), scalars(lookup(after, "spec", "loadBalancerSourceRanges"))
+	if len(beforeRanges) > 0 && len(afterRanges) == 0 {
+		findings = append(findings, finding{ChangeLoosened, "accepts traffic from any source, source ranges were removed"})
+	}
+	if len(beforeRanges) > 0 || !isExposed(before) {
+		for _, r := range subtract(afterRanges, beforeRanges) {
+			findings = append(findings, finding{ChangeLoosened, "accepts traffic from " + r})
+		}
+	}
+
+	if isInternal(before) && !isInternal(after) {
+		findings = append(findings, finding{ChangeLoosened, "load balancer is no longer internal"})
+	}
+	return findings
+}
+
+// isExposed reports whether a service is reachable from outside the cluster
+func isExposed(service *yaml.Node) bool {
+	serviceType := scalar(lookup(service, "spec"), "type")
+	return serviceType == "LoadBalancer" || serviceType == "NodePort"
+}
+
+// internalAnnotations mark load balancers that only get a private address
+var internalAnnotations = []string{"networking.gke.io/load-balancer-type", "cloud.google.com/load-balancer-type"}
+
+// isInternal reports whether a service is an internal load balancer
+func isInternal(service *yaml.Node) bool {
+	for _, annotation := range internalAnnotations {
+		if strings.EqualFold(scalar(lookup(service, "metadata", "annotations"), annotation), "internal") {
+			return true
+		}
+	}
+	return false
+}
+
+// exposedPorts describes the ports of an exposed service, e.g.
+// "443/TCP through a LoadBalancer"
+func exposedPorts(service *yaml.Node) []string {
+	if !isExposed(service) {
+		return nil
+	}
+
+	serviceType := scalar(lookup(service, "spec"), "type")
+	var result []string
+	for _, port := range sequence(lookup(service, "spec", "ports")) {
+		protocol := scalar(port, "protocol")
+		if protocol == "" {
+			protocol = "TCP"
+		}
+		result = append(result, fmt.Sprintf("%s/%s through a %s", scalar(port, "port"), protocol, serviceType))
+	}
+	return result
+}
+
+// detectIngress reports hosts exposed by an Ingress
+func detectIngress(before, after *yaml.Node) []finding {
+	hosts := func(ingress *yaml.Node) []string {
+		var result []string
+		if ingress != nil && lookup(ingress, "spec", "defaultBackend") != nil {
+			result = append(result, "*")
+		}
+		for _, rule := range sequence(lookup(ingress, "spec", "rules")) {
+			result = append(result, orWildcard(scalar(rule, "host")))
+		}
+		return result
+	}
+	return compareHosts(hosts(before), hosts(after))
+}
+
+// detectGateway reports listeners opened or closed on a Gateway
+func detectGateway(before, after *yaml.Node) []finding {
+	listeners := func(gateway *yaml.Node) []string {
+		var result []string
+		for _, listener := range sequence(lookup(gateway, "spec", "listeners")) {
+			result = append(result, fmt.Sprintf("%s %s:%s", scalar(listener, "protocol"), orWildcard(scalar(listener, "hostname")), scalar(listener, "port")))
+		}
+		return result
+	}
+	return compareSets(listeners(before), listeners(after),
+		func(l string) finding { return finding{ChangeAdded, "listens on " + l} },
+		func(l string) finding { return finding{ChangeRemoved, "no longer listens on " + l} })
+}
+
+// detectRoute reports hostnames and gateways an HTTPRoute was attached to
+func detectRoute(before, after *yaml.Node) []finding {
+	hostnames := func(route *yaml.Node) []string {
+		if route == nil {
+			return nil
+		}
+		names := scalars(lookup(route, "spec", "hostnames"))
+		if len(names) == 0 {
+			return []string{"*"}
+		}
+		return names
+	}
+	parents := func(route *yaml.Node) []string {
+		var result []string
+		for _, ref := range sequence(lookup(route, "spec", "parentRefs")) {
+			kind := scalar(ref, "kind")
+			if kind == "" {
+				kind = "Gateway"
+			}
+			name := scalar(ref, "name")
+			if namespace := scalar(ref, "namespace"); namespace != "" {
+				name = namespace + "/" + name
+			}
+			result = append(result, kind+" "+name)
+		}
+		return result
+	}
+
+	return append(compareHosts(hostnames(before), hostnames(after)), compareSets(parents(before), parents(after),
+		func(p string) finding { return finding{ChangeAdded, "attached to " + p} },
+		func(p string) finding { return finding{ChangeRemoved, "no longer attached to " + p} })...)
+}
+
+// compareHosts reports added and removed hosts
+func compareHosts(before, after []string) []finding {
+	return compareSets(before, after,
+		func(h string) finding { return finding{ChangeAdded, "exposes host " + h} },
+		func(h string) finding { return finding{ChangeRemoved, "no longer exposes host " + h} })
+}
+
+// detectIAP returns a detector reporting Identity-Aware Proxy being
+// disabled on a backend policy, with the enabled flag at path
+func detectIAP(path ...string) func(before, after *yaml.Node) []finding {
+	return func(before, after *yaml.Node) []finding {
+		enabled := func(node *yaml.Node) bool {
+			value := lookup(node, path...)
+			return value != nil && value.Value == "true"
+		}
+
+		switch {
+		case enabled(before) && after == nil:
+			return []finding{{ChangeLoosened, "removed, backends are no longer protected by IAP"}}
+		case enabled(before) && !enabled(after):
+			return []finding{{ChangeLoosened, "IAP is disabled"}}
+		case !enabled(before) && enabled(after):
+			return []finding{{ChangeAdded, "IAP is enabled"}}
+		}
+		return nil
+	}
+}
+
+// compareSets reports the entries only in after with added, and the
+// entries only in before with removed
+func compareSets(before, after []string, added, removed func(string) finding) []finding {
+	var findings []finding
+	for _, s := range subtract(after, before) {
+		findings = append(findings, added(s))
+	}
+	for _, s := range subtract(before, after) {
+		findings = append(findings, removed(s))
+	}
+	return findings
+}
+
+// subtract returns the unique entries of a that are not in b, in order
+func subtract(a, b []string) []string {
+	var result []string
+	for _, s := range a {
+		if !slices.Contains(b, s) && !slices.Contains(result, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// lookup follows a path of keys through mapping nodes
+func lookup(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		node = manifest.Lookup(node, key)
+	}
+	return node
+}
+
+// scalar returns the value of a scalar field in a mapping node
+func scalar(node *yaml.Node, key string) string {
+	if value := manifest.Lookup(node, key); value != nil && value.Kind == yaml.ScalarNode {
+		return value.Value
+	}
+	return ""
+}
+
+// sequence returns the entries of a sequence node
+func sequence(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
+
+// scalars returns the values of a sequence of scalars
+func scalars(node *yaml.Node) []string {
+	var result []string
+	for _, entry := range sequence(node) {
+		result = append(result, entry.Value)
+	}
+	return result
+}
+
+// compact formats a node as compact JSON with sorted keys, so equal
+// values always produce the same string
+func compact(node *yaml.Node) string {
+	if node == nil {
+		return "{}"
+	}
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return node.Value
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return node.Value
+	}
+	return string(b)
+}
+
+// orWildcard returns host, or "*" when it is empty
+func orWildcard(host string) string {
+	if host == "" {
+		return "*"
+	}
+	return host
+}
+
+// Write writes a human readable list of findings to w. Nothing is
+// written when there are none.
+func Write(w io.Writer, findings []Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	changeStr := "change"
+	if len(findings) != 1 {
+		changeStr = "changes"
+	}
+	if _, err := fmt.Fprintf(w, "\nSecurity review: %d RBAC or network exposure %s:\n", len(findings), changeStr); err != nil {
+		return err
+	}
+
+	for _, f := range findings {
+		if _, err := fmt.Fprintf(w, "  - [%s] %s\n", f.Change, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
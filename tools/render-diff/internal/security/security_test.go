@@ -0,0 +1,233 @@
+package security
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const clusterRole = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+rules:
+  - apiGroups: [""]
+    resources: [pods]
+    verbs: [get, list]
+`
+
+const binding = `apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: reader
+  namespace: web
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: reader
+subjects:
+  - kind: ServiceAccount
+    name: web
+    namespace: web
+`
+
+const networkPolicy = `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: web
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  policyTypes: [Ingress, Egress]
+  ingress:
+    - from:
+        - podSelector:
+            matchLabels:
+              app: proxy
+`
+
+const service = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  annotations:
+    networking.gke.io/load-balancer-type: Internal
+spec:
+  type: LoadBalancer
+  loadBalancerSourceRanges: [10.0.0.0/8]
+  ports:
+    - port: 443
+`
+
+const ingress = `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+    - host: web.mozilla.org
+`
+
+const route = `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: web
+spec:
+  parentRefs:
+    - name: internal
+      namespace: gateways
+  hostnames: [web.mozilla.org]
+`
+
+const backendConfig = `apiVersion: cloud.google.com/v1
+kind: BackendConfig
+metadata:
+  name: web
+spec:
+  iap:
+    enabled: true
+`
+
+func TestCompare(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		local  string
+		want   []string
+	}{
+		{
+			name:   "No changes",
+			target: strings.Join([]string{clusterRole, binding, networkPolicy, service, ingress, route, backendConfig}, "---\n"),
+			local:  strings.Join([]string{clusterRole, binding, networkPolicy, service, ingress, route, backendConfig}, "---\n"),
+		},
+		{
+			name:   "Permissions granted and revoked",
+			target: clusterRole,
+			local:  strings.Replace(clusterRole, `    verbs: [get, list]`, "    verbs: [get, delete]\n  - apiGroups: [apps]\n    resources: [deployments]\n    resourceNames: [web]\n    verbs: [patch]", 1),
+			want: []string{
+				"added rbac rbac.authorization.k8s.io/v1/ClusterRole/reader: grants delete pods",
+				"added rbac rbac.authorization.k8s.io/v1/ClusterRole/reader: grants patch apps/deployments (web)",
+				"removed rbac rbac.authorization.k8s.io/v1/ClusterRole/reader: no longer grants list pods",
+			},
+		},
+		{
+			name:   "New binding",
+			target: "",
+			local:  binding,
+			want:   []string{"added rbac rbac.authorization.k8s.io/v1/RoleBinding/web/reader: binds ServiceAccount web/web to ClusterRole/reader"},
+		},
+		{
+			name:   "Bound role changed",
+			target: binding,
+			local:  strings.Replace(binding, "  name: reader\nsubjects", "  name: admin\nsubjects", 1),
+			want:   []string{"modified rbac rbac.authorization.k8s.io/v1/RoleBinding/web/reader: binds ClusterRole/admin instead of ClusterRole/reader"},
+		},
+		{
+			name:   "Network policy loosened",
+			target: networkPolicy,
+			local: strings.Replace(strings.Replace(networkPolicy, "[Ingress, Egress]", "[Ingress]", 1),
+				"      app: web\n", "      app: web\n      tier: frontend\n", 1) + "    - from:\n        - namespaceSelector: {}\n",
+			want: []string{
+				`loosened network networking.k8s.io/v1/NetworkPolicy/web: pod selector changed from {"matchLabels":{"app":"web"}} to {"matchLabels":{"app":"web","tier":"frontend"}}`,
+				"loosened network networking.k8s.io/v1/NetworkPolicy/web: no longer restricts egress traffic",
+				`loosened network networking.k8s.io/v1/NetworkPolicy/web: allows ingress {"from":[{"namespaceSelector":{}}]}`,
+			},
+		},
+		{
+			name:   "Network policy removed",
+			target: networkPolicy,
+			local:  "",
+			want:   []string{"loosened network networking.k8s.io/v1/NetworkPolicy/web: removed, the pods it selected are no longer isolated by it"},
+		},
+		{
+			name:   "Load balancer opened up",
+			target: service,
+			local: strings.Replace(strings.Replace(service, "  loadBalancerSourceRanges: [10.0.0.0/8]\n", "", 1),
+				"    networking.gke.io/load-balancer-type: Internal\n", "    team: web\n", 1) + "    - port: 80\n",
+			want: []string{
+				"added network v1/Service/web: exposes 80/TCP through a LoadBalancer",
+				"loosened network v1/Service/web: accepts traffic from any source, source ranges were removed",
+				"loosened network v1/Service/web: load balancer is no longer internal",
+			},
+		},
+		{
+			name:   "Cluster IP service",
+			target: "",
+			local:  strings.Replace(service, "type: LoadBalancer", "type: ClusterIP", 1),
+		},
+		{
+			name:   "Ingress hosts",
+			target: ingress,
+			local:  strings.Replace(ingress, "web.mozilla.org", "web.mozilla.com", 1),
+			want: []string{
+				"added network networking.k8s.io/v1/Ingress/web: exposes host web.mozilla.com",
+				"removed network networking.k8s.io/v1/Ingress/web: no longer exposes host web.mozilla.org",
+			},
+		},
+		{
+			name:   "Route moved to another gateway",
+			target: route,
+			local:  strings.Replace(strings.Replace(route, "name: internal", "name: external", 1), "  hostnames: [web.mozilla.org]\n", "", 1),
+			want: []string{
+				"added network gateway.networking.k8s.io/v1/HTTPRoute/web: exposes host *",
+				"removed network gateway.networking.k8s.io/v1/HTTPRoute/web: no longer exposes host web.mozilla.org",
+				"added network gateway.networking.k8s.io/v1/HTTPRoute/web: attached to Gateway gateways/external",
+				"removed network gateway.networking.k8s.io/v1/HTTPRoute/web: no longer attached to Gateway gateways/internal",
+			},
+		},
+		{
+			name:   "IAP disabled",
+			target: backendConfig,
+			local:  strings.Replace(backendConfig, "enabled: true", "enabled: false", 1),
+			want:   []string{"loosened network cloud.google.com/v1/BackendConfig/web: IAP is disabled"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings, err := Compare(tc.target, tc.local)
+			if err != nil {
+				t.Fatalf("Compare() failed: %v", err)
+			}
+
+			var got []string
+			for _, f := range findings {
+				got = append(got, fmt.Sprintf("%s %s %s", f.Change, f.Category, f))
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Compare() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		if _, err := Compare("a: [b\n", ""); err == nil {
+			t.Error("Compare() expected an error for invalid YAML")
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, nil); err != nil || b.Len() != 0 {
+		t.Errorf("Write() with no findings wrote %q, %v", b.String(), err)
+	}
+
+	findings, err := Compare(backendConfig, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&b, findings); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+Security review: 1 RBAC or network exposure change:
+  - [loosened] cloud.google.com/v1/BackendConfig/web: removed, backends are no longer protected by IAP
+`
+	if b.String() != want {
+		t.Errorf("Write() =\n%s\nwant:\n%s", b.String(), want)
+	}
+}
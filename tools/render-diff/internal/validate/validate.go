@@ -0,0 +1,221 @@
+// Package validate checks rendered manifests against JSON schemas stored
+// on disk in the {group}/{kind}_{version}.json layout used by kubeconform.
+// Schemas are only ever read from the local directory, so validation works offline.
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var printer = message.NewPrinter(language.English)
+
+// Failure is an object that does not match its schema
+type Failure struct {
+	manifest.ResourceID
+	Errors []string `json:"errors"`
+}
+
+// Validator validates objects against the schemas in a directory.
+// Compiled schemas are cached, so a Validator should be reused across renders.
+type Validator struct {
+	dir string
+
+	mu      sync.Mutex
+	schemas map[string]*jsonschema.Schema
+}
+
+// New returns a Validator for the schemas in dir
+func New(dir string) (*Validator, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("schema path '%s' is not a directory", dir)
+	}
+
+	return &Validator{
+		dir:     dir,
+		schemas: make(map[string]*jsonschema.Schema),
+	}, nil
+}
+
+// Validate checks every object in a rendered manifest. Objects without a
+// schema in the directory, such as core Kubernetes types, are skipped.
+func (v *Validator) Validate(render string) ([]Failure, error) {
+	docs, err := manifest.Parse(render)
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []Failure
+	for _, doc := range docs {
+		schema, err := v.schema(doc.ID)
+		if err != nil {
+			return nil, err
+		}
+		if schema == nil {
+			continue
+		}
+
+		instance, err := toJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s for validation: %w", doc.ID, err)
+		}
+
+		err = schema.Validate(instance)
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			failures = append(failures, Failure{ResourceID: doc.ID, Errors: flatten(validationErr)})
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to validate %s: %w", doc.ID, err)
+		}
+	}
+
+	return failures, nil
+}
+
+// schema loads and compiles the schema for an object. It returns nil
+// when the directory has no schema for the object's kind and version.
+func (v *Validator) schema(id manifest.ResourceID) (*jsonschema.Schema, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := id.APIVersion() + "/" + id.Kind
+	if schema, ok := v.schemas[key]; ok {
+		return schema, nil
+	}
+
+	path := v.schemaPath(id)
+	if path == "" {
+		v.schemas[key] = nil
+		return nil, nil
+	}
+
+	schema, err := jsonschema.NewCompiler().Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema %s: %w", path, err)
+	}
+
+	v.schemas[key] = schema
+	return schema, nil
+}
+
+// schemaPath returns the schema file for an object, or an empty string.
+// kubeconform lowercases the kind, but we also accept the kind as written.
+func (v *Validator) schemaPath(id manifest.ResourceID) string {
+	if id.Kind == "" || id.Version == "" {
+		return ""
+	}
+
+	for _, kind := range []string{strings.ToLower(id.Kind), id.Kind} {
+		path := filepath.Join(v.dir, id.Group, fmt.Sprintf("%s_%s.json", kind, id.Version))
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// toJSON converts a parsed YAML document into a JSON value for the validator
+func toJSON(doc *manifest.Document) (any, error) {
+	var value any
+	if err := doc.Node.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonschema.UnmarshalJSON(bytes.NewReader(b))
+}
+
+// flatten collects the leaf errors of a validation error as
+// "<instance path>: <message>" strings
+func flatten(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		location := "/" + strings.Join(err.InstanceLocation, "/")
+		return []string{fmt.Sprintf("%s: %s", location, err.ErrorKind.LocalizedString(printer))}
+	}
+
+	var messages []string
+	for _, cause := range err.Causes {
+		messages = append(messages, flatten(cause)...)
+	}
+	sort.Strings(messages)
+	return messages
+}
+
+// NewFailures returns the failures in local that do not exist in target.
+// An object that already failed in target is only reported with the
+// errors it did not have before.
+func NewFailures(target, local []Failure) []Failure {
+	known := make(map[string]map[string]bool)
+	for _, failure := range target {
+		errs := make(map[string]bool, len(failure.Errors))
+		for _, e := range failure.Errors {
+			errs[e] = true
+		}
+		known[failure.String()] = errs
+	}
+
+	var result []Failure
+	for _, failure := range local {
+		var errs []string
+		for _, e := range failure.Errors {
+			if !known[failure.String()][e] {
+				errs = append(errs, e)
+			}
+		}
+		if len(errs) > 0 {
+			result = append(result, Failure{ResourceID: failure.ResourceID, Errors: errs})
+		}
+	}
+
+	return result
+}
+
+// WriteFailures writes a human readable list of failures to w
+func WriteFailures(w io.Writer, failures []Failure) error {
+	if len(failures) == 0 {
+		_, err := fmt.Fprintln(w, "\nSchema validation: no new failures.")
+		return err
+	}
+
+	objectStr := "object"
+	if len(failures) != 1 {
+		objectStr = "objects"
+	}
+	if _, err := fmt.Fprintf(w, "\nSchema validation: %d %s newly failing:\n", len(failures), objectStr); err != nil {
+		return err
+	}
+
+	for _, failure := range failures {
+		if _, err := fmt.Fprintf(w, "  - %s\n", failure.String()); err != nil {
+			return err
+		}
+		for _, e := range failure.Errors {
+			if _, err := fmt.Fprintf(w, "      %s\n", e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package validate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mozilla/mozcloud/tools/render-diff/internal/manifest"
+)
+
+const schemaDir = "testdata/schemas"
+
+func TestValidate(t *testing.T) {
+	v, err := New(schemaDir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	render := `---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: good
+spec:
+  size: 2
+  color: red
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: bad
+spec:
+  size: 0
+  shape: round
+---
+# No schema for core kinds, so this is skipped
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+`
+
+	failures, err := v.Validate(render)
+	if err != nil {
+		t.Fatalf("Validate() failed: %v", err)
+	}
+
+	if len(failures) != 1 {
+		t.Fatalf("Validate() returned %d failures, want 1: %+v", len(failures), failures)
+	}
+
+	if failures[0].Name != "bad" {
+		t.Errorf("Validate() failure for %q, want %q", failures[0].Name, "bad")
+	}
+
+	joined := strings.Join(failures[0].Errors, "\n")
+	for _, want := range []string{"/spec/size", "/spec"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Validate() errors missing %q. Got:\n%s", want, joined)
+		}
+	}
+}
+
+func TestNewMissingDirectory(t *testing.T) {
+	if _, err := New("testdata/does-not-exist"); err == nil {
+		t.Error("New() succeeded for a missing directory, expected an error")
+	}
+}
+
+func TestNewFailures(t *testing.T) {
+	id := func(name string) manifest.ResourceID {
+		return manifest.ResourceID{Group: "example.com", Version: "v1", Kind: "Widget", Name: name}
+	}
+
+	target := []Failure{
+		{ResourceID: id("still-broken"), Errors: []string{"/spec/size: old error"}},
+		{ResourceID: id("fixed"), Errors: []string{"/spec: error"}},
+	}
+	local := []Failure{
+		{ResourceID: id("still-broken"), Errors: []string{"/spec/size: old error", "/spec/color: new error"}},
+		{ResourceID: id("new"), Errors: []string{"/spec: error"}},
+	}
+
+	got := NewFailures(target, local)
+	if len(got) != 2 {
+		t.Fatalf("NewFailures() returned %d failures, want 2: %+v", len(got), got)
+	}
+
+	if got[0].Name != "still-broken" || len(got[0].Errors) != 1 || got[0].Errors[0] != "/spec/color: new error" {
+		t.Errorf("NewFailures()[0] = %+v, want only the new error on still-broken", got[0])
+	}
+	if got[1].Name != "new" {
+		t.Errorf("NewFailures()[1] = %+v, want the new object", got[1])
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFailures(&buf, got); err != nil {
+		t.Fatalf("WriteFailures() failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "2 objects newly failing") {
+		t.Errorf("WriteFailures() output missing count. Got:\n%s", buf.String())
+	}
+}